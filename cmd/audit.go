@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var outputFormat string
+	var summaryOnly bool
+	var quiet bool
+	findOpts := rom.FindOptions{Recursive: true}
+
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Compare a ROM collection against the datfile",
+		Long: `Compare a ROM collection against the datfile.
+
+Every ROM is matched by its contents. The report lists which datfile entries
+you have, which are missing, files that match nothing (extras), and files with
+the same contents as another file (duplicates), with counts per region.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := findRomFiles(args, findOpts)
+			if err != nil {
+				return err
+			}
+
+			df, err := loadDatfile()
+			if err != nil {
+				return err
+			}
+
+			cache := openHashCache()
+			opts := readOptions{jobs: defaultJobs, hashes: []string{rom.HashRomSHA1}, cache: cache}
+			romfiles, errs := readRomFiles(files, opts)
+			saveHashCache(cache)
+			if len(errs) > 0 && !quiet {
+				printFileErrors("Errors were encountered while reading some files:", errs)
+			}
+
+			report, err := rom.Audit(romfiles, df)
+			if err != nil {
+				return err
+			}
+
+			return formatters.PrintAudit(report, outputFormat, summaryOnly)
+		},
+	}
+
+	auditCmd.Flags().StringVarP(&outputFormat, "output", "o", "table",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	auditCmd.Flags().BoolVarP(&summaryOnly, "summary", "s", false, "Only show counts per region")
+	auditCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Quiet mode. Suppress non-fatal errors.")
+	auditCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
+	addFindFlags(auditCmd, &findOpts)
+
+	rootCmd.AddCommand(auditCmd)
+}
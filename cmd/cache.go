@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+var noCache bool
+var cachePath string
+
+func init() {
+	var verbose bool
+
+	var cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of file hashes",
+		Long: `Manage the cache of file hashes.
+
+Hashes and CRCs are kept between runs so unchanged files don't have to be read again.
+A file is considered unchanged while its size, modification time, and inode are the same.`,
+	}
+
+	var clearCmd = &cobra.Command{
+		Use:   "clear",
+		Short: "Remove everything from the cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := loadHashCache()
+			if err != nil {
+				return err
+			}
+			if err := cache.Clear(); err != nil {
+				return err
+			}
+			fmt.Printf("Cleared %s\n", cache.Path)
+			return nil
+		},
+	}
+
+	var statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show the size of the cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := loadHashCache()
+			if err != nil {
+				return err
+			}
+			stats, err := cache.Stats()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Path:    %s\n", stats.Path)
+			fmt.Printf("Size:    %s\n", formatBytes(stats.Size))
+			fmt.Printf("Entries: %d\n", stats.Entries)
+			fmt.Printf("Stale:   %d\n", stats.Stale)
+			return nil
+		},
+	}
+
+	var pruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove entries for files that are gone or have changed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := loadHashCache()
+			if err != nil {
+				return err
+			}
+			removed := cache.Prune()
+			if err := cache.Save(); err != nil {
+				return err
+			}
+			if verbose {
+				for _, path := range removed {
+					fmt.Println(path)
+				}
+			}
+			fmt.Printf("Removed %d stale entries\n", len(removed))
+			return nil
+		},
+	}
+	pruneCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "List the removed paths")
+
+	cacheCmd.AddCommand(clearCmd, statsCmd, pruneCmd)
+
+	rootCmd.PersistentFlags().BoolVarP(&noCache, "no-cache", "", false, "Don't read or write the hash cache")
+	rootCmd.PersistentFlags().StringVarP(&cachePath, "cache-file", "", "", "Hash cache location. Defaults to rom64/hashes.json in the user cache directory.")
+
+	rootCmd.AddCommand(cacheCmd)
+}
+
+func loadHashCache() (*rom.HashCache, error) {
+	path := cachePath
+	if path == "" {
+		var err error
+		if path, err = rom.DefaultHashCachePath(); err != nil {
+			return nil, err
+		}
+	}
+	return rom.OpenHashCache(path)
+}
+
+// The hash cache for commands that read ROMs. Returns nil when caching is off.
+// A cache that can't be read is reported and skipped rather than stopping the command.
+func openHashCache() *rom.HashCache {
+	if noCache {
+		return nil
+	}
+	cache, err := loadHashCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Not using the hash cache: %s\n", err)
+		return nil
+	}
+	return cache
+}
+
+func saveHashCache(cache *rom.HashCache) {
+	if cache == nil {
+		return
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the hash cache: %s\n", err)
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var outputFormat string
+	var gap int
+
+	var compareCmd = &cobra.Command{
+		Use:   "compare <a> <b>",
+		Short: "Show how two ROMs differ",
+		Long: `Show how two ROMs differ, such as two dumps of the same game or two revisions.
+
+Both ROMs are compared in z64 byte order, so a v64 and a z64 of the same game
+are identical. The header fields that differ are listed, then the byte ranges
+that differ, then how much of each area of the ROM differs:
+
+  header  0x0000-0x0040    ROM header
+  ipl3    0x0040-0x1000    IPL3 bootcode
+  crc     0x1000-0x101000  The first 1MB of the game, covered by CRC1/CRC2
+  data    0x101000-        The rest of the ROM
+
+Differences less than --gap bytes apart are listed as one range.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if gap < 0 {
+				return fmt.Errorf("--gap can't be negative")
+			}
+
+			comparison, err := rom.Compare(args[0], args[1], gap)
+			if err != nil {
+				return err
+			}
+			return formatters.PrintComparison(comparison, outputFormat)
+		},
+	}
+
+	compareCmd.Flags().StringVarP(&outputFormat, "output", "o", "table",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	compareCmd.Flags().IntVarP(&gap, "gap", "g", 16, "Join differences less than this many bytes apart into one range. 0 lists every difference.")
+
+	rootCmd.AddCommand(compareCmd)
+}
@@ -6,21 +6,38 @@ import (
 	"path"
 	"strings"
 
+	"github.com/mroach/rom64/dat"
 	"github.com/mroach/rom64/rom"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	var overwrite bool
+	var targetFormat string
 	var convertCmd = &cobra.Command{
 		Use:   "convert",
-		Short: "Converts a ROM to native Big-Endian Z64 format",
+		Short: "Converts a ROM to another byte order. Defaults to native Big-Endian Z64 format",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			targetFormat = strings.ToLower(targetFormat)
+			if _, ok := rom.FileFormats[targetFormat]; !ok {
+				return fmt.Errorf("Invalid target format '%s'. Must be one of: %s, %s, %s",
+					targetFormat, rom.FormatZ64, rom.FormatV64, rom.FormatN64)
+			}
+
 			inpath := args[0]
-			dirname, filename := path.Split(inpath)
-			baseFilename := basename(filename)
-			outFilename := baseFilename + ".z64"
+			source, err := rom.FromPath(inpath)
+			if err != nil {
+				return err
+			}
+
+			// ROMs in archives are written next to the archive
+			dirname := path.Dir(inpath)
+			if source.File.Archive != "" {
+				dirname = path.Dir(source.File.Archive)
+			}
+			baseFilename := basename(source.File.Name)
+			outFilename := baseFilename + "." + targetFormat
 			outpath := path.Join(dirname, outFilename)
 
 			if !overwrite {
@@ -29,17 +46,27 @@ func init() {
 				}
 			}
 
-			df, err := loadDatfile()
-			if err != nil {
-				return err
+			// The datfile only has checksums for big-endian files
+			validate := targetFormat == rom.FormatZ64
+
+			var df dat.DatFile
+			if validate {
+				if df, err = loadDatfile(); err != nil {
+					return err
+				}
 			}
 
-			if err := rom.ConvertRomFormat(inpath, outpath); err != nil {
+			if err := rom.ConvertRomFormatTo(inpath, outpath, targetFormat); err != nil {
 				return err
 			}
 
 			fmt.Printf("Created %s\n", outpath)
 
+			if !validate {
+				fmt.Println("Conversion complete.")
+				return nil
+			}
+
 			// Read the new ROM info so we can validate it.
 			info, err := rom.FromPath(outpath)
 			if err != nil {
@@ -66,7 +93,9 @@ func init() {
 	}
 
 	convertCmd.Flags().BoolVarP(&overwrite, "force", "f", false, "Overwrite destination file if it exists")
-	convertCmd.Flags().StringVarP(&datFilePath, "datfile", "d", "", "Load custom DAT file (XML format)")
+	convertCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
+	convertCmd.Flags().StringVarP(&targetFormat, "to", "t", rom.FormatZ64,
+		fmt.Sprintf("Target format (%s, %s, %s)", rom.FormatZ64, rom.FormatV64, rom.FormatN64))
 	rootCmd.AddCommand(convertCmd)
 }
 
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/patch"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var outpath string
+	var outputFormat string
+	var overwrite bool
+	var quiet bool
+
+	var diffPatchCmd = &cobra.Command{
+		Use:   "diff-patch <original> <modified>",
+		Short: "Create an IPS or BPS patch from two ROMs",
+		Long: `Create an IPS or BPS patch that turns the original ROM into the modified one.
+
+Both ROMs are converted to z64 byte order first, so the patch applies to the
+z64 version of the original no matter what format either file is in. The patch
+format is chosen by the extension of the output file: .ips or .bps. BPS patches
+carry the CRC32s of the original and modified ROMs; IPS patches have no
+checksums and can't change anything past 16MB.
+
+Without --output, a BPS patch is written next to the modified ROM. The changed
+byte ranges are listed afterwards, with changes less than 16 bytes apart
+listed as one range.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outpath == "" {
+				outpath = strings.TrimSuffix(args[1], filepath.Ext(args[1])) + ".bps"
+				if archive, _, ok := rom.SplitArchivePath(args[1]); ok {
+					outpath = strings.TrimSuffix(archive, filepath.Ext(archive)) + ".bps"
+				}
+			}
+			format, ok := patch.FormatFromPath(outpath)
+			if !ok || format == patch.FormatVCDIFF {
+				return fmt.Errorf("Can't tell the patch format from '%s'. Use a .ips or .bps extension.", outpath)
+			}
+			if !overwrite {
+				if _, err := os.Stat(outpath); err == nil {
+					return fmt.Errorf("Output file already exists: '%s'", outpath)
+				}
+			}
+
+			original, err := rom.ReadRomData(args[0], rom.FormatZ64)
+			if err != nil {
+				return err
+			}
+			modified, err := rom.ReadRomData(args[1], rom.FormatZ64)
+			if err != nil {
+				return err
+			}
+
+			data, err := patch.Create(format, original, modified)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(outpath, data, 0644); err != nil {
+				return err
+			}
+
+			differences := patch.DiffRegions(original, modified)
+			changed := 0
+			for _, region := range differences {
+				changed += region.Size
+			}
+			regions := patch.MergeRegions(differences, 16)
+
+			fmt.Fprintf(os.Stderr, "Created %s patch %s (%d bytes)\n", patch.Formats[format], outpath, len(data))
+			fmt.Fprintf(os.Stderr, "%d bytes changed in %d ranges", changed, len(regions))
+			if len(modified) != len(original) {
+				fmt.Fprintf(os.Stderr, ". Size changed from %d to %d bytes", len(original), len(modified))
+			}
+			fmt.Fprintln(os.Stderr)
+
+			if quiet {
+				return nil
+			}
+			return formatters.PrintPatchRegions(regions, outputFormat)
+		},
+	}
+
+	diffPatchCmd.Flags().StringVarP(&outpath, "output", "o", "", "Patch file to create. The extension sets the format: .ips or .bps")
+	diffPatchCmd.Flags().StringVarP(&outputFormat, "format", "", "table",
+		fmt.Sprintf("Format of the list of changes (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	diffPatchCmd.Flags().BoolVarP(&overwrite, "force", "f", false, "Overwrite the patch file if it exists")
+	diffPatchCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't list the changed byte ranges")
+
+	rootCmd.AddCommand(diffPatchCmd)
+}
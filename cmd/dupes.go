@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var outputFormat string
+	var deleteDupes bool
+	var linkDupes bool
+	var confirmed bool
+	var quiet bool
+	findOpts := rom.FindOptions{Recursive: true}
+
+	var dupesCmd = &cobra.Command{
+		Use:   "dupes",
+		Short: "Find copies of the same ROM, even in different byte orders",
+		Long: `Find copies of the same ROM, even in different byte orders.
+
+Files are grouped by the SHA-1 of their contents in z64 byte order, so a .z64 and
+a .v64 of the same game are duplicates. In each group, the copy to keep is listed
+first. Files that aren't symlinks are preferred, then z64 files, then files named like
+the datfile entry, then plain files over ones in archives, then the shortest path.
+
+With --delete or --link, the other copies are only shown until --yes is given.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if deleteDupes && linkDupes {
+				return fmt.Errorf("Use either --delete or --link, not both")
+			}
+
+			files, err := findRomFiles(args, findOpts)
+			if err != nil {
+				return err
+			}
+
+			df, err := loadDatfile()
+			if err != nil {
+				return err
+			}
+
+			// Linking needs the file hash to make sure the files are identical
+			hashes := []string{rom.HashRomSHA1}
+			if linkDupes {
+				hashes = append(hashes, rom.HashSHA1)
+			}
+
+			cache := openHashCache()
+			romfiles, errs := readRomFiles(files, readOptions{jobs: defaultJobs, hashes: hashes, cache: cache})
+			saveHashCache(cache)
+			if len(errs) > 0 && !quiet {
+				printFileErrors("Errors were encountered while reading some files:", errs)
+			}
+
+			action := rom.DupeExtra
+			if deleteDupes {
+				action = rom.DupeDelete
+			} else if linkDupes {
+				action = rom.DupeLink
+			}
+
+			groups := rom.FindDuplicates(romfiles, &df)
+			pending := 0
+			for i := range groups {
+				groups[i].Plan(action)
+				for _, file := range groups[i].Files {
+					if file.Action == action && action != rom.DupeExtra {
+						pending++
+					}
+				}
+			}
+
+			if err := formatters.PrintDupes(groups, outputFormat); err != nil {
+				return err
+			}
+
+			if pending == 0 {
+				return nil
+			}
+			if !confirmed {
+				fmt.Fprintf(os.Stderr, "Nothing was changed. Use --yes to %s %d files.\n", action, pending)
+				return nil
+			}
+
+			failures := make([]fileError, 0)
+			for _, group := range groups {
+				for _, err := range group.Apply() {
+					failures = append(failures, fileError{group.SHA1, err})
+				}
+			}
+			if len(failures) > 0 {
+				printFileErrors("Some files could not be changed:", failures)
+				return fmt.Errorf("%d of %d files could not be changed", len(failures), pending)
+			}
+
+			fmt.Fprintf(os.Stderr, "%d files changed (%s)\n", pending, action)
+			return nil
+		},
+	}
+
+	dupesCmd.Flags().StringVarP(&outputFormat, "output", "o", "table",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	dupesCmd.Flags().BoolVarP(&deleteDupes, "delete", "", false, "Delete the copies that aren't kept")
+	dupesCmd.Flags().BoolVarP(&linkDupes, "link", "", false, "Replace identical copies with hard links to the kept file")
+	dupesCmd.Flags().BoolVarP(&confirmed, "yes", "y", false, "Really delete or link files")
+	dupesCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Quiet mode. Suppress non-fatal errors.")
+	dupesCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
+	addFindFlags(dupesCmd, &findOpts)
+
+	rootCmd.AddCommand(dupesCmd)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+var editHeaderFlags = []string{
+	"image-name", "cartridge-id", "region", "media-format", "version", "clock-rate",
+	"save-type", "rtc", "region-free", "no-homebrew",
+}
+
+func init() {
+	var imageName string
+	var cartridgeId string
+	var regionCode string
+	var mediaFormat string
+	var version string
+	var clockRate string
+	var saveType string
+	var rtc bool
+	var regionFree bool
+	var noHomebrew bool
+	var dryRun bool
+	var noFixCrc bool
+	var outputFormat string
+
+	var editHeaderCmd = &cobra.Command{
+		Use:   "edit-header <rom>",
+		Short: "Change fields in the ROM header",
+		Long: `Change fields in the ROM header. The file is changed in place, in its own byte order,
+and the fields that changed are shown. Afterwards the header CRCs are recalculated
+like fix-crc does.
+
+Setting any homebrew option adds the ED64 homebrew header, which replaces the
+cartridge ID with "ED" and the version with the homebrew config. Options that
+aren't given keep their current values.
+
+A ROM with the homebrew header can only get a new cartridge ID or version when the
+header is removed with --no-homebrew, which needs a new --cartridge-id.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			changed := false
+			for _, name := range editHeaderFlags {
+				changed = changed || flags.Changed(name)
+			}
+			if !changed {
+				return fmt.Errorf("Nothing to change. See --help for the fields that can be set.")
+			}
+
+			info, err := rom.FromPath(args[0])
+			if err != nil {
+				return err
+			}
+
+			var changes rom.HeaderChanges
+			if flags.Changed("image-name") {
+				changes.ImageName = &imageName
+			}
+			if flags.Changed("cartridge-id") {
+				changes.CartridgeId = &cartridgeId
+			}
+			if flags.Changed("region") {
+				code := strings.ToUpper(regionCode)
+				changes.RegionCode = &code
+			}
+			if flags.Changed("media-format") {
+				code := strings.ToUpper(mediaFormat)
+				changes.MediaFormat = &code
+			}
+			if flags.Changed("version") {
+				v, err := rom.ParseVersion(version)
+				if err != nil {
+					return err
+				}
+				changes.Version = &v
+			}
+			if flags.Changed("clock-rate") {
+				rate, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(clockRate), "0x"), 16, 32)
+				if err != nil {
+					return fmt.Errorf("Invalid clock rate '%s'. Use a 32-bit hexadecimal number like 0000000F.", clockRate)
+				}
+				r := uint32(rate)
+				changes.ClockRate = &r
+			}
+			if flags.Changed("save-type") || flags.Changed("rtc") || flags.Changed("region-free") {
+				var hb rom.HomebrewHeader
+				if info.Homebrew != nil {
+					hb = *info.Homebrew
+				} else {
+					hb.SaveType.Code = rom.SaveNone
+				}
+				if flags.Changed("save-type") {
+					hb.SaveType.Code = strings.ToLower(saveType)
+				}
+				if flags.Changed("rtc") {
+					hb.RTC = rtc
+				}
+				if flags.Changed("region-free") {
+					hb.RegionFree = regionFree
+				}
+				changes.Homebrew = &hb
+			}
+			changes.RemoveHomebrew = noHomebrew
+
+			differences, err := info.EditHeader(changes, dryRun)
+			if err != nil {
+				return err
+			}
+			if len(differences) == 0 {
+				fmt.Fprintln(os.Stderr, "No changes")
+				return nil
+			}
+
+			if !dryRun && !noFixCrc {
+				if err := fixHeaderCRC(&info); err != nil {
+					return err
+				}
+			}
+
+			return formatters.PrintHeaderChanges(differences, outputFormat)
+		},
+	}
+
+	editHeaderCmd.Flags().StringVarP(&imageName, "image-name", "", "",
+		fmt.Sprintf("Image name, up to %d ASCII characters", rom.IMAGE_NAME_LENGTH))
+	editHeaderCmd.Flags().StringVarP(&cartridgeId, "cartridge-id", "", "", "Two character cartridge ID, like SM")
+	editHeaderCmd.Flags().StringVarP(&regionCode, "region", "", "", "Region code, like E for North America")
+	editHeaderCmd.Flags().StringVarP(&mediaFormat, "media-format", "", "", "Media format code, like N for cartridge")
+	editHeaderCmd.Flags().StringVarP(&version, "version", "", "", "Version, like 1.1")
+	editHeaderCmd.Flags().StringVarP(&clockRate, "clock-rate", "", "", "Clock rate override in hexadecimal, like 0000000F")
+	editHeaderCmd.Flags().StringVarP(&saveType, "save-type", "", "",
+		fmt.Sprintf("Homebrew save type (%s)", strings.Join(rom.HomebrewSaveTypes(), ", ")))
+	editHeaderCmd.Flags().BoolVarP(&rtc, "rtc", "", false, "Homebrew: enable the real-time clock")
+	editHeaderCmd.Flags().BoolVarP(&regionFree, "region-free", "", false, "Homebrew: run on consoles from any region")
+	editHeaderCmd.Flags().BoolVarP(&noHomebrew, "no-homebrew", "", false, "Remove the homebrew header. Needs --cartridge-id.")
+	editHeaderCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show the fields that would change without writing them")
+	editHeaderCmd.Flags().BoolVarP(&noFixCrc, "no-fix-crc", "", false, "Don't recalculate the header CRCs")
+	editHeaderCmd.Flags().StringVarP(&outputFormat, "output", "o", "table",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+
+	rootCmd.AddCommand(editHeaderCmd)
+}
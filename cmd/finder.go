@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+// Add the flags that control how directories are searched for ROMs.
+// Values already set in opts are used as the defaults.
+func addFindFlags(cmd *cobra.Command, opts *rom.FindOptions) {
+	cmd.Flags().BoolVarP(&opts.Recursive, "recursive", "r", opts.Recursive, "Search subdirectories")
+	cmd.Flags().IntVarP(&opts.MaxDepth, "max-depth", "", 0, "Maximum directory depth when recursive. 0 for no limit.")
+	cmd.Flags().StringSliceVarP(&opts.Include, "include", "", make([]string, 0),
+		"Only include files matching these glob patterns, instead of filtering by extension")
+	cmd.Flags().StringSliceVarP(&opts.Exclude, "exclude", "", make([]string, 0), "Skip files and directories matching these glob patterns")
+	cmd.Flags().BoolVarP(&opts.FollowSymlinks, "follow-symlinks", "L", false, "Descend into symlinked directories")
+	cmd.Flags().BoolVarP(&opts.Sniff, "sniff", "", false, "Detect ROMs by their contents instead of file extensions")
+}
+
+// Find ROM files in all the given files and directories. A file found through
+// more than one of the paths is only listed once.
+func findRomFiles(paths []string, opts rom.FindOptions) ([]string, error) {
+	files := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		found, err := rom.FindRomsInPath(path, opts)
+		if err != nil {
+			return files, err
+		}
+		for _, file := range found {
+			key := absPath(file)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
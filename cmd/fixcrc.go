@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+var defaultFixCrcColumns = []string{
+	"file_name", "file_format", "cic", "cic_confidence",
+	"crc1", "file_crc1", "crc2", "file_crc2", "crc_valid",
+}
+
+func init() {
+	var outputFormat string
+	var columns []string
+	var dryRun bool
+	var force bool
+	var findOpts rom.FindOptions
+
+	var fixCrcCmd = &cobra.Command{
+		Use:   "fix-crc",
+		Short: "Recalculate the CRC1/CRC2 checksums and write them to the ROM header",
+		Long: `Recalculate the CRC1/CRC2 checksums and write them to the ROM header.
+
+The output shows the CRCs found in the header (crc1, crc2) next to the
+calculated values (file_crc1, file_crc2) from before the file was changed.
+
+When the bootcode is unknown, every CIC seed is tried to find the one that gives
+the CRCs in the header. When the CIC still can't be identified, the CRCs are
+calculated as for a 6102, which may be wrong. Those files are skipped unless
+--force is given.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(columns) == 0 {
+				columns = defaultFixCrcColumns
+			}
+
+			columns, err := validateColumns(columns)
+			if err != nil {
+				printColumnHelp()
+				return err
+			}
+
+			files, err := findRomFiles(args, findOpts)
+			if err != nil {
+				return err
+			}
+
+			results := make([]rom.RomFile, 0, len(files))
+			errs := make([]fileError, 0)
+			for _, path := range files {
+				info, err := rom.FromPath(path)
+				if err != nil {
+					appendError(&errs, path, err)
+					continue
+				}
+
+				if err = info.CalcCRCSearchingSeeds(); err != nil {
+					appendError(&errs, path, err)
+					continue
+				}
+
+				// Keep the pre-fix values for the report
+				results = append(results, info)
+
+				if dryRun || info.CRCValid() {
+					continue
+				}
+
+				if info.CICConfidence == rom.CICConfidenceLow && !force {
+					fmt.Fprintf(os.Stderr, "Skipping %s: unknown CIC. Use --force to write the CRCs anyway.\n", info.File.Path)
+					continue
+				}
+
+				if err = info.WriteCRC(); err != nil {
+					appendError(&errs, info.File.Path, err)
+				}
+			}
+
+			if err := formatters.PrintAll(results, outputFormat, columns); err != nil {
+				return err
+			}
+
+			if len(errs) > 0 {
+				printFileErrors("Some files could not be fixed:", errs)
+				return fmt.Errorf("%d of %d files could not be fixed", len(errs), len(files))
+			}
+			return nil
+		},
+	}
+
+	fixCrcCmd.Flags().StringVarP(&outputFormat, "output", "o", "table",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	fixCrcCmd.Flags().StringSliceVarP(&columns, "columns", "c", make([]string, 0), "Column selection")
+	fixCrcCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would change without writing to the file")
+	fixCrcCmd.Flags().BoolVarP(&force, "force", "f", false, "Write CRCs even when the CIC is unknown")
+
+	addFindFlags(fixCrcCmd, &findOpts)
+
+	rootCmd.AddCommand(fixCrcCmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var gameDBPath string
+
+	rootCmd.PersistentFlags().StringVarP(&gameDBPath, "gamedb", "", "",
+		"Game database CSV to use instead of the built-in one. Same columns as rom/gamedb.csv.")
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if gameDBPath == "" {
+			return nil
+		}
+		return rom.LoadGameDB(gameDBPath)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/rom"
+)
+
+// Which hash needs to be calculated to fill in each column
+var columnHashes = map[string]string{
+	"file_md5":    rom.HashMD5,
+	"file_sha1":   rom.HashSHA1,
+	"file_sha256": rom.HashSHA256,
+	"file_crc32":  rom.HashCRC32,
+	"file_crc1":   rom.HashN64CRC,
+	"file_crc2":   rom.HashN64CRC,
+	"crc_valid":   rom.HashN64CRC,
+	"rom_md5":     rom.HashRomMD5,
+	"rom_sha1":    rom.HashRomSHA1,
+	"rom_crc32":   rom.HashRomCRC32,
+}
+
+// Hashes that are calculated when no columns are selected
+var defaultHashes = []string{rom.HashMD5, rom.HashSHA1, rom.HashCRC32, rom.HashN64CRC, rom.HashRomSHA1}
+
+// Find the hashes needed to fill in the given columns
+func hashesForColumns(columns []string) []string {
+	hashes := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, column := range columns {
+		if hash, ok := columnHashes[column]; ok && !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+
+	// Datfile matching is done with the normalized SHA-1
+	if formatters.NeedsDat(columns) && !seen[rom.HashRomSHA1] {
+		hashes = append(hashes, rom.HashRomSHA1)
+	}
+
+	return hashes
+}
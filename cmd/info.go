@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -26,7 +27,13 @@ func init() {
 				return err
 			}
 
-			if len(columns) == 0 {
+			// Without a column selection, calculate everything except the slower SHA-256
+			hashes := defaultHashes
+			matchDat := true
+			if len(columns) > 0 {
+				hashes = hashesForColumns(columns)
+				matchDat = formatters.NeedsDat(columns)
+			} else {
 				columns = formatters.DefaultColumns(outputFormat)
 			}
 
@@ -36,12 +43,20 @@ func init() {
 				return err
 			}
 
-			if err = info.AddHashes(); err != nil {
+			cache := openHashCache()
+			if err = cache.CalcHashes(context.Background(), &info, nil, hashes...); err != nil {
 				return err
 			}
+			saveHashCache(cache)
 
-			if err = info.CalcCRC(); err != nil {
-				return err
+			if matchDat {
+				df, err := loadDatfile()
+				if err != nil {
+					return err
+				}
+				if err = info.MatchDat(df); err != nil {
+					return err
+				}
 			}
 
 			return formatters.PrintOne(info, outputFormat, columns)
@@ -51,6 +66,7 @@ func init() {
 	infoCmd.Flags().StringVarP(&outputFormat, "output", "o", "text",
 		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
 	infoCmd.Flags().StringSliceVarP(&columns, "columns", "c", make([]string, 0), "Column selection")
+	infoCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
 
 	rootCmd.AddCommand(infoCmd)
 }
@@ -1,13 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
-	"sync"
+	"time"
 
+	"github.com/mroach/rom64/dat"
 	"github.com/mroach/rom64/formatters"
 	"github.com/mroach/rom64/rom"
 	"github.com/spf13/cobra"
@@ -17,9 +19,10 @@ func init() {
 	var outputFormat string
 	var columns []string
 	var quiet bool
-	calcMd5 := false
-	calcSha := false
-	calcCrc := false
+	var jobs int
+	var noProgress bool
+	var streamOutput bool
+	var findOpts rom.FindOptions
 
 	var lsCmd = &cobra.Command{
 		Use:     "ls",
@@ -27,14 +30,13 @@ func init() {
 		Short:   "Find and list Nintendo 64 ROMs",
 		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			path := args[0]
-			files, err := rom.FindProbableRomsInPath(path)
+			files, err := findRomFiles(args, findOpts)
 			if err != nil {
 				return err
 			}
 
 			if len(files) == 0 {
-				return fmt.Errorf("No ROM files found in '%s'", path)
+				return fmt.Errorf("No ROM files found in '%s'", strings.Join(args, "', '"))
 			}
 
 			if len(columns) == 0 {
@@ -47,72 +49,97 @@ func init() {
 				return err
 			}
 
-			for _, column := range columns {
-				if column == "file_md5" {
-					calcMd5 = true
+			hashes := hashesForColumns(columns)
+
+			var datfile *dat.DatFile
+			if formatters.NeedsDat(columns) {
+				df, err := loadDatfile()
+				if err != nil {
+					return err
 				}
-				if column == "file_sha1" {
-					calcSha = true
+				datfile = &df
+			}
+
+			// Stop hashing on Ctrl-C
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			cache := openHashCache()
+			defer saveHashCache(cache)
+
+			opts := readOptions{jobs: jobs, hashes: hashes, datfile: datfile, cache: cache}
+
+			var ticks <-chan time.Time
+			if !noProgress && stdoutIsTerminal() {
+				opts.progress = newProgress(os.Stderr, len(files))
+				ticker := time.NewTicker(200 * time.Millisecond)
+				defer ticker.Stop()
+				ticks = ticker.C
+			}
+
+			// With --stream, formats that allow it are printed as each file is done, in no particular order
+			var stream formatters.RomStream
+			if streamOutput {
+				if !formatters.CanStream(outputFormat) {
+					return fmt.Errorf("The %s format can't be streamed. Use csv, tab, or text.", outputFormat)
 				}
-				if column == "file_crc1" || column == "file_crc2" {
-					calcCrc = true
+				if stream, err = formatters.NewRomStream(outputFormat, columns); err != nil {
+					return err
 				}
 			}
 
-			results := make(chan rom.RomFile, len(files))
-			errs := make(chan struct {
-				string
-				error
-			}, len(files))
-
-			var wg sync.WaitGroup
-			for _, rompath := range files {
-				wg.Add(1)
-				go func(rompath string) {
-					defer wg.Done()
-					info, err := rom.FromPath(rompath)
-					if err != nil {
-						sendError(errs, rompath, err)
-						return
+			results, errc := streamRomFiles(ctx, files, opts)
+			fileInfos := make([]rom.RomFile, 0, len(files))
+			errs := make([]fileError, 0)
+
+			for results != nil || errc != nil {
+				select {
+				case info, ok := <-results:
+					if !ok {
+						results = nil
+						continue
+					}
+					if stream == nil {
+						fileInfos = append(fileInfos, info)
+						continue
 					}
-					if calcMd5 {
-						if err := info.AddMD5(); err != nil {
-							sendError(errs, rompath, err)
-						}
+					if opts.progress != nil {
+						opts.progress.clear()
 					}
-					if calcSha {
-						if err := info.AddSHA1(); err != nil {
-							sendError(errs, rompath, err)
-						}
+					if err := stream.Print(info); err != nil {
+						return err
 					}
-					if calcCrc {
-						if err := info.CalcCRC(); err != nil {
-							sendError(errs, rompath, err)
-						}
+				case item, ok := <-errc:
+					if !ok {
+						errc = nil
+						continue
 					}
-					results <- info
-				}(rompath)
+					errs = append(errs, item)
+				case <-ticks:
+					opts.progress.render()
+				}
 			}
-			wg.Wait()
-			close(errs)
-			close(results)
 
-			fileInfos := make([]rom.RomFile, 0)
-			for info := range results {
-				fileInfos = append(fileInfos, info)
+			if opts.progress != nil {
+				opts.progress.clear()
 			}
-			sort.Slice(fileInfos, func(i, j int) bool {
-				return fileInfos[i].File.Name < fileInfos[j].File.Name
-			})
 
 			if len(errs) > 0 && !quiet {
-				l := log.New(os.Stderr, "", 1)
-				l.Println("Errors were encountered while listing some files:")
-				for item := range errs {
-					l.Printf("%s: %s\n", item.string, item.error)
-				}
+				printFileErrors("Errors were encountered while listing some files:", errs)
+			}
+
+			if ctx.Err() != nil {
+				return fmt.Errorf("Interrupted")
+			}
+
+			if stream != nil {
+				return stream.Close()
 			}
 
+			sort.Slice(fileInfos, func(i, j int) bool {
+				return fileInfos[i].File.Name < fileInfos[j].File.Name
+			})
+
 			return formatters.PrintAll(fileInfos, outputFormat, columns)
 		},
 	}
@@ -121,16 +148,12 @@ func init() {
 		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
 	lsCmd.Flags().StringSliceVarP(&columns, "columns", "c", make([]string, 0), "Column selection")
 	lsCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Quiet mode. Suppress non-fatal errors.")
+	lsCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
+	lsCmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs, "Number of files to read at the same time")
+	lsCmd.Flags().BoolVarP(&noProgress, "no-progress", "", false, "Don't show a progress bar")
+	lsCmd.Flags().BoolVarP(&streamOutput, "stream", "", false,
+		"Print csv, tab, and text rows as each file is read, in no particular order, instead of sorted by file name")
+	addFindFlags(lsCmd, &findOpts)
 
 	rootCmd.AddCommand(lsCmd)
 }
-
-func sendError(queue chan struct {
-	string
-	error
-}, path string, err error) {
-	queue <- struct {
-		string
-		error
-	}{path, err}
-}
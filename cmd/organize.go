@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+const defaultOrganizeTemplate = "{{.Region.Short | safe}}/{{.ImageName}} ({{.Serial}}).{{.File.Format.Code}}"
+
+func init() {
+	var pathTemplate string
+	var mode string
+	var onCollision string
+	var journalPath string
+	var dryRun bool
+	var quiet bool
+	findOpts := rom.FindOptions{Recursive: true}
+
+	var organizeCmd = &cobra.Command{
+		Use:   "organize <source> <destination>",
+		Short: "Copy, move, or link ROMs into a directory structure built from a template",
+		Long: `Copy, move, or link ROMs into a directory structure built from a template.
+
+The template is a Go template executed for each ROM. Every field of the ROM
+is available, such as {{.ImageName}}, {{.Serial}}, {{.Region.Short}}, {{.CIC}},
+and {{.File.Format.Code}}. When the ROM matches the datfile, the game is available
+as {{.Game.Name}}, {{.Game.Description}}, and {{.Game.Category}}. ROMs without a match
+are skipped unless the template checks for it: {{if .Game}}...{{else}}...{{end}}
+
+Functions: safe (replace / so a value stays in one directory), lower, upper, trim.
+
+Every change is written to a journal so it can be undone with 'organize rollback'.`,
+		Example: fmt.Sprintf(`  %[1]s organize ~/Downloads ~/n64 --template "{{.Region.Short | safe}}/{{.ImageName}} ({{.Serial}}).z64"
+  %[1]s organize ~/Downloads ~/n64 --mode move --template "{{.Game.Name}}.{{.File.Format.Code}}"`, binName),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, destination := args[0], args[1]
+
+			if !contains(rom.OrganizeModes, mode) {
+				return fmt.Errorf("Invalid mode '%s'. Valid modes: %s", mode, strings.Join(rom.OrganizeModes, ", "))
+			}
+			if !contains(rom.CollisionPolicies, onCollision) {
+				return fmt.Errorf("Invalid collision policy '%s'. Valid policies: %s", onCollision, strings.Join(rom.CollisionPolicies, ", "))
+			}
+
+			tmpl, err := rom.ParsePathTemplate(pathTemplate)
+			if err != nil {
+				return err
+			}
+
+			files, err := findRomFiles([]string{source}, findOpts)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("No ROM files found in '%s'", source)
+			}
+
+			// Hashing is only needed to find the datfile game
+			opts := readOptions{jobs: defaultJobs}
+			if rom.TemplateUsesDat(tmpl) {
+				df, err := loadDatfile()
+				if err != nil {
+					return err
+				}
+				opts.hashes = []string{rom.HashRomSHA1}
+				opts.datfile = &df
+				opts.cache = openHashCache()
+			}
+
+			romfiles, errs := readRomFiles(files, opts)
+			saveHashCache(opts.cache)
+
+			sort.Slice(romfiles, func(i, j int) bool {
+				return romfiles[i].File.Path < romfiles[j].File.Path
+			})
+
+			var journal *rom.Journal
+			defer func() {
+				if journal != nil {
+					journal.Close()
+					fmt.Printf("Journal written to %s\n", journal.Path)
+					fmt.Printf("Undo with: %s organize rollback \"%s\"\n", binName, journal.Path)
+				}
+			}()
+
+			// Destinations picked in this run, which may not exist yet during a dry run
+			planned := make(map[string]bool)
+			taken := func(fpath string) bool { return planned[fpath] }
+
+			for i := range romfiles {
+				romfile := &romfiles[i]
+
+				rel, err := romfile.RenderPath(tmpl)
+				if err != nil {
+					appendError(&errs, romfile.File.Path, err)
+					continue
+				}
+				dest := filepath.Join(destination, rel)
+
+				if rom.SameFile(romfile.File.Path, dest) {
+					if !quiet {
+						fmt.Printf("Already in place: %s\n", dest)
+					}
+					continue
+				}
+
+				_, existed := os.Lstat(dest)
+				target, err := rom.ResolveCollision(dest, onCollision, taken)
+				if err != nil {
+					return err
+				}
+				if target == "" && planned[dest] {
+					appendError(&errs, romfile.File.Path, fmt.Errorf("Another ROM was already organized to '%s'. Skipping.", dest))
+					continue
+				}
+				if target == "" {
+					appendError(&errs, romfile.File.Path, fmt.Errorf("Destination '%s' already exists. Skipping.", dest))
+					continue
+				}
+				planned[target] = true
+
+				prefix := ""
+				if dryRun {
+					prefix = "[dry run] "
+				}
+				fmt.Printf("%s%s \"%s\" => \"%s\"\n", prefix, mode, romfile.File.Path, target)
+				if dryRun {
+					continue
+				}
+
+				if journal == nil {
+					if journal, err = createJournal(journalPath, destination); err != nil {
+						return err
+					}
+				}
+
+				if err := rom.OrganizeFile(romfile, target, mode); err != nil {
+					appendError(&errs, romfile.File.Path, err)
+					continue
+				}
+
+				// Absolute paths so the journal works from any directory
+				entry := rom.JournalEntry{
+					Mode:     mode,
+					From:     absPath(romfile.File.Path),
+					To:       absPath(target),
+					Root:     absPath(destination),
+					Replaced: target == dest && existed == nil,
+				}
+				if err := journal.Record(entry); err != nil {
+					return err
+				}
+			}
+
+			if len(errs) > 0 && !quiet {
+				printFileErrors("Some files were not organized:", errs)
+			}
+
+			return nil
+		},
+	}
+
+	var rollbackCmd = &cobra.Command{
+		Use:   "rollback <journal>",
+		Short: "Undo the changes recorded in an organize journal",
+		Long: `Undo the changes recorded in an organize journal.
+
+Moved files are moved back, and copies and links are removed, newest first.
+Directories left empty are removed. Files that replaced an existing file can't be undone.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := rom.ReadJournal(args[0])
+			if err != nil {
+				return err
+			}
+
+			errs := make([]fileError, 0)
+			for i := len(entries) - 1; i >= 0; i-- {
+				entry := entries[i]
+				if dryRun {
+					fmt.Printf("[dry run] undo %s \"%s\" => \"%s\"\n", entry.Mode, entry.From, entry.To)
+					continue
+				}
+				if err := entry.Rollback(); err != nil {
+					appendError(&errs, entry.To, err)
+					continue
+				}
+				fmt.Printf("Undid %s \"%s\" => \"%s\"\n", entry.Mode, entry.From, entry.To)
+			}
+
+			if len(errs) > 0 {
+				printFileErrors("Some changes could not be undone:", errs)
+				return fmt.Errorf("%d of %d changes could not be undone", len(errs), len(entries))
+			}
+
+			if !dryRun {
+				return os.Remove(args[0])
+			}
+			return nil
+		},
+	}
+	rollbackCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only show what would be undone")
+
+	organizeCmd.Flags().StringVarP(&pathTemplate, "template", "t", defaultOrganizeTemplate, "Template for the path of each ROM, relative to the destination")
+	organizeCmd.Flags().StringVarP(&mode, "mode", "m", rom.OrganizeCopy,
+		fmt.Sprintf("How to put files in place (%s)", strings.Join(rom.OrganizeModes, ", ")))
+	organizeCmd.Flags().StringVarP(&onCollision, "on-collision", "", rom.CollisionSkip,
+		fmt.Sprintf("What to do when the destination exists (%s)", strings.Join(rom.CollisionPolicies, ", ")))
+	organizeCmd.Flags().StringVarP(&journalPath, "journal", "", "", "Where to write the journal. Defaults to a file in the destination.")
+	organizeCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only show what would be done")
+	organizeCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Quiet mode. Suppress non-fatal errors.")
+	organizeCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
+	addFindFlags(organizeCmd, &findOpts)
+
+	organizeCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(organizeCmd)
+}
+
+func createJournal(path string, destination string) (*rom.Journal, error) {
+	if path == "" {
+		name := fmt.Sprintf(".rom64-organize-%s.jsonl", time.Now().Format("20060102-150405"))
+		path = filepath.Join(destination, name)
+	}
+	return rom.CreateJournal(path)
+}
+
+func appendError(errs *[]fileError, path string, err error) {
+	*errs = append(*errs, fileError{path, err})
+}
+
+func absPath(fpath string) string {
+	if abs, err := filepath.Abs(fpath); err == nil {
+		return abs
+	}
+	return fpath
+}
+
+func contains(items []string, item string) bool {
+	for _, other := range items {
+		if other == item {
+			return true
+		}
+	}
+	return false
+}
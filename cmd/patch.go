@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mroach/rom64/formatters"
+	"github.com/mroach/rom64/patch"
+	"github.com/mroach/rom64/rom"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var outputFormat string
+	var columns []string
+	var overwrite bool
+	var ignoreChecksums bool
+	var noConvert bool
+	var noFixCrc bool
+
+	var patchCmd = &cobra.Command{
+		Use:   "patch <rom> <patch> [output]",
+		Short: "Apply an IPS, BPS, or xdelta patch to a ROM",
+		Long: `Apply an IPS, BPS, or xdelta patch to a ROM and write the patched ROM to a new file.
+
+N64 patches are almost always made against z64 ROMs, so v64 and n64 ROMs are
+converted to z64 before patching. BPS patches carry the CRC32 of the ROM they're
+for, so a ROM in another byte order is patched as it is when that's what the
+patch expects. BPS checksums and xdelta window checksums are checked.
+
+The header CRCs of the patched ROM are recalculated and written, unless the CIC
+can't be identified. Without an output path, the patched ROM is written next to
+the source ROM, named after the patch.`,
+		Args: cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			romPath, patchPath := args[0], args[1]
+
+			hashes := defaultHashes
+			if len(columns) > 0 {
+				hashes = hashesForColumns(columns)
+			} else {
+				columns = formatters.DefaultColumns(outputFormat)
+			}
+			columns, err := validateColumns(columns)
+			if err != nil {
+				printColumnHelp()
+				return err
+			}
+
+			patchData, err := os.ReadFile(patchPath)
+			if err != nil {
+				return err
+			}
+			patchFormat, err := patch.Detect(patchData)
+			if err != nil {
+				return err
+			}
+
+			source, err := rom.FromPath(romPath)
+			if err != nil {
+				return err
+			}
+
+			targetFormat := source.File.Format.Code
+			if !noConvert && !source.IsDisk() && !bpsExpectsFile(romPath, patchData, targetFormat) {
+				targetFormat = rom.FormatZ64
+			}
+
+			sourceData, err := rom.ReadRomData(romPath, targetFormat)
+			if err != nil {
+				return err
+			}
+
+			outpath := patchOutputPath(source, patchPath, targetFormat)
+			if len(args) > 2 {
+				outpath = args[2]
+			}
+			if !overwrite {
+				if _, err := os.Stat(outpath); err == nil {
+					return fmt.Errorf("Output file already exists: '%s'", outpath)
+				}
+			}
+			if rom.SameFile(romPath, outpath) {
+				return fmt.Errorf("The output can't be the ROM being patched: '%s'", outpath)
+			}
+
+			patched, err := patch.Apply(sourceData, patchData, patch.Options{IgnoreChecksums: ignoreChecksums})
+			var checksumErr *patch.ChecksumError
+			if errors.As(err, &checksumErr) {
+				return fmt.Errorf("%w. Use --ignore-checksums to apply it anyway.", err)
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(outpath, patched, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Applied %s patch. Created %s\n", patch.Formats[patchFormat], outpath)
+
+			info, err := rom.FromPath(outpath)
+			if err != nil {
+				return fmt.Errorf("Patched file isn't a ROM rom64 can read: %w", err)
+			}
+
+			if !noFixCrc && !info.IsDisk() {
+				if err := fixHeaderCRC(&info); err != nil {
+					return err
+				}
+			}
+
+			if err := info.CalcHashes(hashes...); err != nil {
+				return err
+			}
+
+			return formatters.PrintOne(info, outputFormat, columns)
+		},
+	}
+
+	patchCmd.Flags().StringVarP(&outputFormat, "output", "o", "text",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	patchCmd.Flags().StringSliceVarP(&columns, "columns", "c", make([]string, 0), "Column selection")
+	patchCmd.Flags().BoolVarP(&overwrite, "force", "f", false, "Overwrite the output file if it exists")
+	patchCmd.Flags().BoolVarP(&ignoreChecksums, "ignore-checksums", "", false, "Apply the patch even when its checksums don't match")
+	patchCmd.Flags().BoolVarP(&noConvert, "no-convert", "", false, "Patch the ROM in its own byte order instead of converting it to z64")
+	patchCmd.Flags().BoolVarP(&noFixCrc, "no-fix-crc", "", false, "Don't recalculate the header CRCs of the patched ROM")
+
+	rootCmd.AddCommand(patchCmd)
+}
+
+// Whether a BPS patch was made against the ROM file as it is, rather than converted to z64
+func bpsExpectsFile(romPath string, patchData []byte, romFormat string) bool {
+	expected, ok := patch.SourceCRC32(patchData)
+	if !ok || romFormat == rom.FormatZ64 {
+		return false
+	}
+
+	data, err := rom.ReadRomData(romPath, romFormat)
+	if err != nil {
+		return false
+	}
+	return crc32.ChecksumIEEE(data) == expected
+}
+
+// The patch name with the ROM's extension, next to the ROM. ROMs in archives get theirs next to the archive.
+func patchOutputPath(source rom.RomFile, patchPath string, romFormat string) string {
+	dir := filepath.Dir(source.File.Path)
+	if source.File.Archive != "" {
+		dir = filepath.Dir(source.File.Archive)
+	}
+	return filepath.Join(dir, basename(filepath.Base(patchPath))+"."+romFormat)
+}
+
+// Recalculate the CRCs of a changed ROM and write them when they don't match the header
+func fixHeaderCRC(info *rom.RomFile) error {
+	if err := info.CalcCRC(); err != nil {
+		return err
+	}
+	if info.CRCValid() {
+		return nil
+	}
+	if info.CICConfidence == rom.CICConfidenceLow {
+		fmt.Fprintf(os.Stderr, "Not updating the CRCs: unknown CIC. Use fix-crc --force to write them anyway.\n")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Updating CRCs: %s %s -> %s %s\n", info.CRC1, info.CRC2, info.File.CRC1, info.File.CRC2)
+	return info.WriteCRC()
+}
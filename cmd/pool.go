@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Default number of files to work on at the same time
+var defaultJobs = runtime.NumCPU()
+
+// Call work for every item using at most jobs goroutines.
+// The index of the item is passed along so results can be kept in order.
+func forEachConcurrently(items []string, jobs int, work func(i int, item string)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				work(i, items[i])
+			}
+		}()
+	}
+
+	for i := range items {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Progress bar for long-running commands, drawn on a single line
+type progress struct {
+	out        io.Writer
+	totalFiles int
+	// Sizes are added by the workers as files are opened
+	totalBytes int64
+	sizedFiles int64
+	doneFiles  int64
+	doneBytes  int64
+	start      time.Time
+	// Whether the bar is currently on screen
+	drawn bool
+}
+
+func newProgress(out io.Writer, totalFiles int) *progress {
+	return &progress{out: out, totalFiles: totalFiles, start: time.Now()}
+}
+
+// Add the size of a file to the total
+func (p *progress) addFile(size int64) {
+	atomic.AddInt64(&p.totalBytes, size)
+	atomic.AddInt64(&p.sizedFiles, 1)
+}
+
+// Counts bytes as they're hashed
+func (p *progress) Write(b []byte) (int, error) {
+	atomic.AddInt64(&p.doneBytes, int64(len(b)))
+	return len(b), nil
+}
+
+func (p *progress) fileDone() {
+	atomic.AddInt64(&p.doneFiles, 1)
+}
+
+func (p *progress) render() {
+	const width = 30
+
+	files := atomic.LoadInt64(&p.doneFiles)
+	bytes := atomic.LoadInt64(&p.doneBytes)
+	totalBytes := atomic.LoadInt64(&p.totalBytes)
+
+	// Go by bytes when hashing, since file sizes vary a lot,
+	// but only once every file's size is known
+	fraction := float64(files) / float64(p.totalFiles)
+	if bytes > 0 && totalBytes > 0 && atomic.LoadInt64(&p.sizedFiles) == int64(p.totalFiles) {
+		fraction = float64(bytes) / float64(totalBytes)
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	eta := "--"
+	if elapsed := time.Since(p.start); fraction > 0 {
+		remaining := time.Duration(float64(elapsed)/fraction) - elapsed
+		eta = remaining.Round(time.Second).String()
+	}
+
+	filled := int(fraction * width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+
+	line := fmt.Sprintf("[%s] %d/%d files", bar, files, p.totalFiles)
+	if bytes > 0 {
+		line += fmt.Sprintf("  %s/%s", formatBytes(bytes), formatBytes(totalBytes))
+	}
+	line += "  ETA " + eta
+
+	fmt.Fprintf(p.out, "\r\033[K%s", line)
+	p.drawn = true
+}
+
+// Remove the progress bar so other output can be printed
+func (p *progress) clear() {
+	if p.drawn {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Whether stdout is an interactive terminal rather than a file, pipe, or /dev/null
+func stdoutIsTerminal() bool {
+	stat, err := os.Stdout.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(stat, null) {
+		return false
+	}
+	return true
+}
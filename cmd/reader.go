@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mroach/rom64/dat"
+	"github.com/mroach/rom64/rom"
+)
+
+type fileError struct {
+	string
+	error
+}
+
+// Settings for reading and hashing many ROM files
+type readOptions struct {
+	jobs   int
+	hashes []string
+	// When set, each ROM is matched against the datfile
+	datfile *dat.DatFile
+	// When set, counts files and bytes as they're read
+	progress *progress
+	// When set, cached hashes are used and new ones are added
+	cache *rom.HashCache
+}
+
+// Read ROM info and calculate hashes for all the files concurrently.
+// Files that can't be read at all are left out of the results.
+func readRomFiles(files []string, opts readOptions) ([]rom.RomFile, []fileError) {
+	results, errs := streamRomFiles(context.Background(), files, opts)
+
+	fileInfos := make([]rom.RomFile, 0, len(files))
+	fileErrors := make([]fileError, 0)
+	for results != nil || errs != nil {
+		select {
+		case info, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			fileInfos = append(fileInfos, info)
+		case item, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			fileErrors = append(fileErrors, item)
+		}
+	}
+
+	return fileInfos, fileErrors
+}
+
+// Read ROM info and calculate hashes using at most opts.jobs goroutines.
+// ROMs are sent as soon as they're ready, so they arrive in no particular order.
+// Both channels are closed when all files are done or ctx is cancelled,
+// and both must be drained.
+func streamRomFiles(ctx context.Context, files []string, opts readOptions) (<-chan rom.RomFile, <-chan fileError) {
+	results := make(chan rom.RomFile, opts.jobs)
+	errs := make(chan fileError, opts.jobs)
+
+	go func() {
+		defer close(results)
+		defer close(errs)
+
+		forEachConcurrently(files, opts.jobs, func(_ int, rompath string) {
+			if ctx.Err() != nil {
+				return
+			}
+			if opts.progress != nil {
+				defer opts.progress.fileDone()
+				// Sizing the files here keeps archives from being opened one at a time up front
+				var size int64
+				if contentSize, err := rom.ContentSize(rompath); err == nil {
+					size = contentSize
+				}
+				opts.progress.addFile(size)
+			}
+
+			info, err := rom.FromPath(rompath)
+			if err != nil {
+				sendError(errs, rompath, err)
+				return
+			}
+
+			// A nil *progress would make a non-nil io.Writer
+			var counter io.Writer
+			if opts.progress != nil {
+				counter = opts.progress
+			}
+			if err := opts.cache.CalcHashes(ctx, &info, counter, opts.hashes...); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				sendError(errs, rompath, err)
+			} else if opts.datfile != nil {
+				if err := info.MatchDat(*opts.datfile); err != nil {
+					sendError(errs, rompath, err)
+				}
+			}
+			results <- info
+		})
+	}()
+
+	return results, errs
+}
+
+func printFileErrors(message string, errs []fileError) {
+	l := log.New(os.Stderr, "", 1)
+	l.Println(message)
+	for _, item := range errs {
+		l.Printf("%s: %s\n", item.string, item.error)
+	}
+}
+
+func sendError(queue chan fileError, path string, err error) {
+	queue <- fileError{path, err}
+}
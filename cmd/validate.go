@@ -2,111 +2,142 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/mroach/rom64/dat"
+	"github.com/mroach/rom64/formatters"
 	"github.com/mroach/rom64/rom"
 	"github.com/spf13/cobra"
 )
 
-var datFilePath string
+var datFilePaths []string
 var renameValidated bool
 
 func init() {
+	var outputFormat string
+	var jobs int
+	var findOpts rom.FindOptions
+
 	var validateCmd = &cobra.Command{
 		Use:   "validate",
-		Short: "Validate the hash of a ROM against a known-good list",
+		Short: "Validate the hash of ROMs against a known-good list",
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			romFilePath := args[0]
-
-			var df dat.DatFile
-			var err error
-
-			romfile, err := rom.FromPath(romFilePath)
+			files, err := findRomFiles(args, findOpts)
 			if err != nil {
 				return err
 			}
 
-			if romfile.Serial() == "" {
-				return fmt.Errorf("ROM has no serial number which is required to look it up in the DAT file.")
+			if len(files) == 0 {
+				return fmt.Errorf("No ROM files found in '%s'", strings.Join(args, "', '"))
 			}
 
-			if err = romfile.AddSHA1(); err != nil {
-				return err
-			}
-
-			df, err = loadDatfile()
+			df, err := loadDatfile()
 			if err != nil {
 				return err
 			}
 
-			matches, mismatches, err := romfile.ValidateWithDat(df)
-			if err != nil {
+			cache := openHashCache()
+			results := make([]rom.ValidationResult, len(files))
+			forEachConcurrently(files, jobs, func(i int, rompath string) {
+				results[i] = rom.ValidatePath(rompath, df, cache)
+			})
+			saveHashCache(cache)
+
+			if err := formatters.PrintValidation(results, outputFormat); err != nil {
 				return err
 			}
 
-			var matchCount = len(matches)
-
-			if matchCount == 0 {
-				fmt.Println("Could not find a checksum match.")
-				fmt.Printf("File '%s' has SHA-1 %s", romfile.File.Name, romfile.File.SHA1)
-				fmt.Println("The datfile has the following entries for this ROM:")
-				for _, mismatch := range mismatches {
-					fmt.Printf("  %-5s %50s %s", "SHA-1\n", mismatch.SHA1, mismatch.Name)
+			failures := 0
+			for _, result := range results {
+				if !result.OK() {
+					failures++
 				}
-				return fmt.Errorf("%s validation failed", romFilePath)
 			}
 
-			fmt.Printf("Found %d datfile entries for ROM serial '%s'\n", matchCount, romfile.Serial())
-
-			for _, match := range matches {
-				fmt.Printf("%-5s \033[32m%-6s\033[0m %40s \"%s\"\n", "SHA-1", "MATCH", match.SHA1, match.Name)
-			}
-
-			if matchCount > 1 {
-				return fmt.Errorf("Multiple datfile entries found with the same SHA-1 hash. This shouldn't happen. Aborting.")
-			}
-
-			var match = matches[0]
-
+			// Renaming is done one file at a time so two files with the same contents can't race
 			if renameValidated {
-				var correctName = match.Name
-				if romfile.File.Name == correctName {
-					fmt.Printf("ROM file already has the correct name \"%s\"\n", correctName)
-					return nil
+				// Keep structured output parseable
+				var out io.Writer = os.Stdout
+				if outputFormat != "text" {
+					out = os.Stderr
 				}
 
-				var sourcePath = romfile.File.Path
-				var newPath = path.Join(path.Dir(sourcePath), correctName)
-
-				fmt.Printf("Renaming \"%s\" => \"%s\"\n", romfile.File.Path, newPath)
-
-				if _, err := os.Stat(newPath); err == nil {
-					return fmt.Errorf("Destination file \"%s\" already exists. Aborting.", newPath)
+				for _, result := range results {
+					if !result.OK() {
+						continue
+					}
+					if err := renameToDatName(out, *result.Rom, *result.Match); err != nil {
+						fmt.Fprintln(out, err)
+						failures++
+					}
 				}
+			}
 
-				err = os.Rename(sourcePath, newPath)
-				if err != nil {
-					return err
-				}
+			if failures > 0 {
+				return fmt.Errorf("%d of %d files failed validation", failures, len(results))
 			}
 
 			return nil
 		},
 	}
 
-	validateCmd.Flags().StringVarP(&datFilePath, "datfile", "d", "", "Load custom DAT file (XML format)")
+	validateCmd.Flags().StringVarP(&outputFormat, "output", "o", "text",
+		fmt.Sprintf("Output format (%s)", strings.Join(formatters.OutputFormats, ", ")))
+	validateCmd.Flags().IntVarP(&jobs, "jobs", "j", defaultJobs, "Number of files to validate at the same time")
+	validateCmd.Flags().StringArrayVarP(&datFilePaths, "datfile", "d", nil, "Load custom DAT file (XML format). Repeat to merge several.")
 	validateCmd.Flags().BoolVarP(&renameValidated, "rename-validated", "", false, "Rename validated files to match the filename in the datefile.")
+	addFindFlags(validateCmd, &findOpts)
 
 	rootCmd.AddCommand(validateCmd)
 }
 
+func renameToDatName(out io.Writer, romfile rom.RomFile, match dat.Rom) error {
+	var correctName = match.Name
+	// Keep the extension matching the byte order since the file wasn't converted
+	if romfile.File.Format.Code != rom.FormatZ64 {
+		correctName = basename(correctName) + "." + romfile.File.Format.Code
+	}
+	if romfile.File.Name == correctName {
+		fmt.Fprintf(out, "ROM file already has the correct name \"%s\"\n", correctName)
+		return nil
+	}
+
+	if romfile.File.Archive != "" {
+		return fmt.Errorf("Can't rename ROMs inside archives: %s", romfile.File.Path)
+	}
+
+	var sourcePath = romfile.File.Path
+	var newPath = path.Join(path.Dir(sourcePath), correctName)
+
+	fmt.Fprintf(out, "Renaming \"%s\" => \"%s\"\n", romfile.File.Path, newPath)
+
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("Destination file \"%s\" already exists. Skipping.", newPath)
+	}
+
+	return os.Rename(sourcePath, newPath)
+}
+
+// Read the datfiles given with --datfile, merged into one, or the included ones
 func loadDatfile() (dat.DatFile, error) {
-	if datFilePath == "" {
+	if len(datFilePaths) == 0 {
 		return dat.ReadFromIncluded()
-	} else {
-		return dat.ReadFromFile(datFilePath)
 	}
+
+	df, err := dat.ReadFromFile(datFilePaths[0])
+	if err != nil {
+		return df, err
+	}
+	for _, path := range datFilePaths[1:] {
+		other, err := dat.ReadFromFile(path)
+		if err != nil {
+			return df, err
+		}
+		df.Merge(other)
+	}
+	return df, nil
 }
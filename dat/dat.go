@@ -14,17 +14,51 @@ var embeddedDatFile []byte
 type DatFile struct {
 	Name    string `xml:"header>name"`
 	Version string `xml:"header>version"`
-	Roms    []Rom  `xml:"game>rom"`
+	Games   []Game `xml:"game"`
+	// Every ROM from every game. Filled in when the datfile is read.
+	Roms []Rom `xml:"-"`
+
+	index *index
+}
+
+// The parts of a <game> record that are copied to each of its ROMs
+type GameInfo struct {
+	Name        string `xml:"name,attr" json:"name"`
+	Description string `xml:"description" json:"description"`
+	CloneOf     string `xml:"cloneof,attr,omitempty" json:"clone_of,omitempty"`
+	Category    string `xml:"category,omitempty" json:"category,omitempty"`
+}
+
+type Game struct {
+	GameInfo
+	Roms []Rom `xml:"rom" json:"roms"`
 }
 
 type Rom struct {
-	Name   string `xml:"name,attr"`
-	Size   int    `xml:"size,attr"`
-	Serial string `xml:"serial,attr"`
-	CRC32  string `xml:"crc32,attr"`
-	MD5    string `xml:"md5,attr"`
-	SHA1   string `xml:"sha1,attr"`
-	Status string `xml:"status"`
+	Name   string   `xml:"name,attr" json:"name"`
+	Size   int      `xml:"size,attr" json:"size"`
+	Serial string   `xml:"serial,attr" json:"serial"`
+	CRC32  string   `xml:"crc,attr" json:"crc32"`
+	MD5    string   `xml:"md5,attr" json:"md5"`
+	SHA1   string   `xml:"sha1,attr" json:"sha1"`
+	Status string   `xml:"status,attr,omitempty" json:"status,omitempty"`
+	Game   GameInfo `xml:"game" json:"game"`
+}
+
+const StatusVerified = "verified"
+
+// Whether the dump has been verified by multiple people
+func (r *Rom) Verified() bool {
+	return r.Status == StatusVerified
+}
+
+// Index positions in Roms for each hash and size, so lookups don't have to scan every entry
+type index struct {
+	sha1   map[string][]int
+	md5    map[string][]int
+	crc32  map[string][]int
+	serial map[string][]int
+	size   map[int][]int
 }
 
 func ReadFromIncluded() (df DatFile, err error) {
@@ -35,6 +69,13 @@ func ReadFromIncluded() (df DatFile, err error) {
 	return df, nil
 }
 
+// Add the games from another datfile. The name and version are kept.
+func (df *DatFile) Merge(other DatFile) {
+	df.Games = append(df.Games, other.Games...)
+	df.Roms = append(df.Roms, other.Roms...)
+	df.BuildIndex()
+}
+
 // Read a DatFile from an XML datfile on disk
 func ReadFromFile(path string) (df DatFile, err error) {
 	f, err := os.Open(path)
@@ -56,15 +97,83 @@ func Read(xmlbytes []byte) (df DatFile, err error) {
 	if err := xml.Unmarshal(xmlbytes, &df); err != nil {
 		return df, err
 	}
+
+	for _, game := range df.Games {
+		for _, rom := range game.Roms {
+			rom.Game = game.GameInfo
+			df.Roms = append(df.Roms, rom)
+		}
+	}
+
+	df.BuildIndex()
 	return df, nil
 }
 
-// Find entries based on a serial, such as NSME or CZLP
-func (df *DatFile) FindBySerial(serial string) (results []Rom) {
-	for _, rom := range df.Roms {
-		if strings.EqualFold(rom.Serial, serial) {
-			results = append(results, rom)
-		}
+// Index the entries by hash, serial, and size. This is done when reading a
+// datfile, but has to be called again if Roms is changed afterwards.
+func (df *DatFile) BuildIndex() {
+	idx := &index{
+		sha1:   make(map[string][]int),
+		md5:    make(map[string][]int),
+		crc32:  make(map[string][]int),
+		serial: make(map[string][]int),
+		size:   make(map[int][]int),
+	}
+
+	for i, rom := range df.Roms {
+		addToIndex(idx.sha1, rom.SHA1, i)
+		addToIndex(idx.md5, rom.MD5, i)
+		addToIndex(idx.crc32, rom.CRC32, i)
+		addToIndex(idx.serial, rom.Serial, i)
+		idx.size[rom.Size] = append(idx.size[rom.Size], i)
+	}
+
+	df.index = idx
+}
+
+func addToIndex(idx map[string][]int, key string, pos int) {
+	if key == "" {
+		return
+	}
+	key = strings.ToUpper(key)
+	idx[key] = append(idx[key], pos)
+}
+
+func (df *DatFile) getIndex() *index {
+	if df.index == nil {
+		df.BuildIndex()
+	}
+	return df.index
+}
+
+func (df *DatFile) lookup(positions []int) (results []Rom) {
+	for _, pos := range positions {
+		results = append(results, df.Roms[pos])
 	}
 	return results
 }
+
+// Find entries based on a serial, such as NSME or CZLP
+func (df *DatFile) FindBySerial(serial string) (results []Rom) {
+	return df.lookup(df.getIndex().serial[strings.ToUpper(serial)])
+}
+
+// Find entries with the given SHA-1 hash. Case-insensitive.
+func (df *DatFile) FindBySHA1(sha1 string) (results []Rom) {
+	return df.lookup(df.getIndex().sha1[strings.ToUpper(sha1)])
+}
+
+// Find entries with the given MD5 hash. Case-insensitive.
+func (df *DatFile) FindByMD5(md5 string) (results []Rom) {
+	return df.lookup(df.getIndex().md5[strings.ToUpper(md5)])
+}
+
+// Find entries with the given CRC32. Case-insensitive.
+func (df *DatFile) FindByCRC32(crc32 string) (results []Rom) {
+	return df.lookup(df.getIndex().crc32[strings.ToUpper(crc32)])
+}
+
+// Find entries with the given size in bytes
+func (df *DatFile) FindBySize(size int) (results []Rom) {
+	return df.lookup(df.getIndex().size[size])
+}
@@ -0,0 +1,125 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"text/template"
+
+	"github.com/mroach/rom64/rom"
+)
+
+var auditEntryHeaders = []string{"Status", "Region", "Name", "Path", "SHA1"}
+var auditSummaryHeaders = []string{"Region", "Have", "Missing", "Extra", "Duplicate", "Error"}
+
+// Print an audit report. With summaryOnly, only the counts per region are printed.
+func PrintAudit(report rom.AuditReport, outputFormat string, summaryOnly bool) error {
+	entries := auditEntryRecords(report)
+	summary := auditSummaryRecords(report)
+
+	switch outputFormat {
+	case "csv", "tab":
+		w := csv.NewWriter(os.Stdout)
+		if outputFormat == "tab" {
+			w.Comma = '\t'
+		}
+		if summaryOnly {
+			return writeCsvRecords(w, auditSummaryHeaders, summary)
+		}
+		return writeCsvRecords(w, auditEntryHeaders, entries)
+	case "json":
+		if summaryOnly {
+			return PrintJson(struct {
+				Regions []rom.AuditSummary `json:"regions"`
+				Total   rom.AuditSummary   `json:"total"`
+			}{report.Regions, report.Total})
+		}
+		return PrintJson(report)
+	case "table":
+		if !summaryOnly {
+			printTable(auditEntryHeaders, entries)
+		}
+		printTable(auditSummaryHeaders, summary)
+		return nil
+	case "text":
+		return printAuditText(report, summaryOnly)
+	case "xml":
+		var doc interface{} = struct {
+			rom.AuditReport
+			XMLName struct{} `xml:"audit"`
+		}{AuditReport: report}
+		if summaryOnly {
+			doc = struct {
+				Regions []rom.AuditSummary `xml:"regions>region"`
+				Total   rom.AuditSummary   `xml:"total"`
+				XMLName struct{}           `xml:"audit"`
+			}{Regions: report.Regions, Total: report.Total}
+		}
+		bytes, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s%s\n", xml.Header, bytes)
+		return err
+	}
+
+	return fmt.Errorf("Invalid output format '%s'", outputFormat)
+}
+
+func auditEntryRecords(report rom.AuditReport) [][]string {
+	records := make([][]string, 0, len(report.Entries))
+	for _, entry := range report.Entries {
+		sha1 := ""
+		if entry.Rom != nil {
+			sha1 = entry.Rom.NormalizedSHA1()
+		} else if entry.Dat != nil {
+			sha1 = entry.Dat.SHA1
+		}
+		records = append(records, []string{entry.Status, entry.Region, entry.Name(), entry.Path(), sha1})
+	}
+	return records
+}
+
+func auditSummaryRecords(report rom.AuditReport) [][]string {
+	records := make([][]string, 0, len(report.Regions)+1)
+	for _, summary := range append(report.Regions, report.Total) {
+		records = append(records, []string{
+			summary.Region,
+			strconv.Itoa(summary.Have),
+			strconv.Itoa(summary.Missing),
+			strconv.Itoa(summary.Extra),
+			strconv.Itoa(summary.Duplicate),
+			strconv.Itoa(summary.Error),
+		})
+	}
+	return records
+}
+
+func writeCsvRecords(w *csv.Writer, headers []string, records [][]string) error {
+	if err := w.Write(headers); err != nil {
+		return err
+	}
+	return w.WriteAll(records)
+}
+
+var auditTextFormat = `{{if not .SummaryOnly}}{{range .Report.Entries}}{{printf "%-10s %-7s %s" .Status .Region .Name}}{{if .Path}}
+           {{.Path}}{{end}}{{if .Message}}
+           {{.Message}}{{end}}
+{{end}}
+{{end}}{{printf "%-10s %8s %8s %8s %10s %6s" "Region" "Have" "Missing" "Extra" "Duplicate" "Error"}}
+{{range .Report.Regions}}{{template "summary" .}}{{end}}{{template "summary" .Report.Total}}`
+
+var auditSummaryFormat = `{{define "summary"}}{{printf "%-10s %8d %8d %8d %10d %6d" .Region .Have .Missing .Extra .Duplicate .Error}}
+{{end}}`
+
+func printAuditText(report rom.AuditReport, summaryOnly bool) error {
+	tmpl := template.Must(template.New("audit").Parse(auditTextFormat))
+	template.Must(tmpl.Parse(auditSummaryFormat))
+
+	return tmpl.Execute(os.Stdout, struct {
+		Report      rom.AuditReport
+		SummaryOnly bool
+	}{report, summaryOnly})
+}
@@ -6,8 +6,10 @@ package formatters
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/mroach/rom64/dat"
 	"github.com/mroach/rom64/rom"
 )
 
@@ -25,9 +27,24 @@ var Columns = map[string]Column{
 		"File name on disk",
 		func(r rom.RomFile) string { return r.File.Name },
 	},
+	"file_path": {
+		"Path",
+		"Path to the file. For ROMs in archives: archive path and entry name separated by #",
+		func(r rom.RomFile) string { return r.File.Path },
+	},
+	"archive": {
+		"Archive",
+		"Path to the archive containing the ROM. Empty for plain files.",
+		func(r rom.RomFile) string { return r.File.Archive },
+	},
+	"archive_entry": {
+		"Archive Entry",
+		"Name of the ROM inside its archive. Empty for plain files.",
+		func(r rom.RomFile) string { return r.File.ArchiveEntry },
+	},
 	"file_format": {
 		"File Format",
-		"File format code. One of: z64, v64, n64",
+		"File format code. One of: z64, v64, n64, or ndd, d64 for 64DD disks",
 		func(r rom.RomFile) string { return r.File.Format.Code },
 	},
 	"file_format_desc": {
@@ -55,6 +72,31 @@ var Columns = map[string]Column{
 		"SHA-1 hash/checksum of the file on disk. Lower-case hexadecimal.",
 		func(r rom.RomFile) string { return r.File.SHA1 },
 	},
+	"file_sha256": {
+		"SHA256",
+		"SHA-256 hash/checksum of the file on disk. Lower-case hexadecimal.",
+		func(r rom.RomFile) string { return r.File.SHA256 },
+	},
+	"file_crc32": {
+		"CRC32",
+		"CRC32 of the file on disk, as used in No-Intro datfiles. Upper-case hexadecimal.",
+		func(r rom.RomFile) string { return r.File.CRC32 },
+	},
+	"rom_md5": {
+		"ROM MD5",
+		"MD5 of the ROM data in Z64 byte order, regardless of the file format. Lower-case hexadecimal.",
+		func(r rom.RomFile) string { return r.MD5 },
+	},
+	"rom_sha1": {
+		"ROM SHA1",
+		"SHA-1 of the ROM data in Z64 byte order, regardless of the file format. Lower-case hexadecimal.",
+		func(r rom.RomFile) string { return r.SHA1 },
+	},
+	"rom_crc32": {
+		"ROM CRC32",
+		"CRC32 of the ROM data in Z64 byte order, regardless of the file format. Upper-case hexadecimal.",
+		func(r rom.RomFile) string { return r.CRC32 },
+	},
 	"file_crc1": {
 		"Calculated CRC-1",
 		"CRC 1 (CRC HI) calculated from the ROM file.",
@@ -65,6 +107,83 @@ var Columns = map[string]Column{
 		"CRC 2 (CRC LO) calculated from the ROM file.",
 		func(r rom.RomFile) string { return r.File.CRC2 },
 	},
+	"crc_valid": {
+		"CRC Valid",
+		"Whether the header CRCs match the calculated CRCs. yes or no. Requires file_crc1/file_crc2.",
+		func(r rom.RomFile) string {
+			if r.File.CRC1 == "" || r.IsDisk() {
+				return ""
+			}
+			return yesNo(r.CRCValid())
+		},
+	},
+	"disk_region": {
+		"Drive Region",
+		"Region of 64DD drive the disk is for. JPN, USA, or DEV. Empty for cartridges.",
+		func(r rom.RomFile) string { return diskValue(r, func(d *rom.DiskInfo) string { return d.Region.Code }) },
+	},
+	"disk_type": {
+		"Disk Type",
+		"64DD disk type, 0 to 6. Decides how much of the disk is writable. Empty for cartridges.",
+		func(r rom.RomFile) string {
+			return diskValue(r, func(d *rom.DiskInfo) string { return fmt.Sprintf("%d", d.Type) })
+		},
+	},
+	"disk_number": {
+		"Disk Number",
+		"Number of the disk in a multi-disk game. Empty for cartridges.",
+		func(r rom.RomFile) string {
+			return diskValue(r, func(d *rom.DiskInfo) string { return fmt.Sprintf("%d", d.DiskNumber) })
+		},
+	},
+	"disk_company": {
+		"Company",
+		"Company code from the 64DD disk ID. example: 01 for Nintendo. Empty for cartridges.",
+		func(r rom.RomFile) string { return diskValue(r, func(d *rom.DiskInfo) string { return d.Company }) },
+	},
+	"save_type": {
+		"Save Type",
+		"Save type from the homebrew header or the game database. One of: none, eeprom4k, eeprom16k, sram256k, sram768k, flashram, sram1m. Empty for unknown games.",
+		func(r rom.RomFile) string { return r.SaveType().Code },
+	},
+	"save_type_desc": {
+		"Save Type",
+		"Save type description. example: EEPROM 4Kbit",
+		func(r rom.RomFile) string { return r.SaveType().Description },
+	},
+	"accessories": {
+		"Accessories",
+		"Accessories the game can use. Any of: controller_pak, rumble_pak, transfer_pak, expansion_pak, 64dd.",
+		func(r rom.RomFile) string {
+			return featuresValue(r, func(f *rom.GameFeatures) string { return strings.Join(f.Accessories, ",") })
+		},
+	},
+	"players": {
+		"Players",
+		"Maximum number of players from the game database.",
+		func(r rom.RomFile) string {
+			return featuresValue(r, func(f *rom.GameFeatures) string { return strconv.Itoa(f.Players) })
+		},
+	},
+	"homebrew": {
+		"Homebrew",
+		"Whether the ROM has the ED64 homebrew header. yes or no.",
+		func(r rom.RomFile) string { return yesNo(r.IsHomebrew()) },
+	},
+	"homebrew_rtc": {
+		"RTC",
+		"Whether the homebrew header enables the real-time clock. yes or no. Empty for other ROMs.",
+		func(r rom.RomFile) string {
+			return homebrewValue(r, func(h *rom.HomebrewHeader) string { return yesNo(h.RTC) })
+		},
+	},
+	"homebrew_region_free": {
+		"Region Free",
+		"Whether the homebrew header makes the ROM region-free. yes or no. Empty for other ROMs.",
+		func(r rom.RomFile) string {
+			return homebrewValue(r, func(h *rom.HomebrewHeader) string { return yesNo(h.RegionFree) })
+		},
+	},
 	"image_name": {
 		"Image Name",
 		"Image name / game title embedded in the ROM.",
@@ -95,6 +214,16 @@ var Columns = map[string]Column{
 		"CIC chip type. example: 6102",
 		func(r rom.RomFile) string { return r.CIC },
 	},
+	"cic_confidence": {
+		"CIC Confidence",
+		"How sure the CIC detection is. high: known IPL3. medium: libdragon or found by the header CRCs. low: unknown.",
+		func(r rom.RomFile) string { return r.CICConfidence },
+	},
+	"ipl3_crc32": {
+		"IPL3 CRC32",
+		"CRC32 of the IPL3 bootcode. Identifies the CIC.",
+		func(r rom.RomFile) string { return r.IPL3CRC32 },
+	},
 	"crc1": {
 		"CRC-1",
 		"CRC1 checksum of ROM internals. Also known as 'CRC HI'",
@@ -110,6 +239,76 @@ var Columns = map[string]Column{
 		"ROM ID / serial. example: NSME for Super Mario 64 (USA)",
 		func(r rom.RomFile) string { return r.Serial() },
 	},
+	"dat_name": {
+		"Datfile Name",
+		"File name of the matching datfile entry. Requires a datfile match.",
+		func(r rom.RomFile) string { return datValue(r, func(d *dat.Rom) string { return d.Name }) },
+	},
+	"dat_game": {
+		"Game",
+		"Game name of the matching datfile entry. example: Super Mario 64 (USA)",
+		func(r rom.RomFile) string { return datValue(r, func(d *dat.Rom) string { return d.Game.Name }) },
+	},
+	"dat_status": {
+		"Status",
+		"Dump status from the datfile. example: verified",
+		func(r rom.RomFile) string { return datValue(r, func(d *dat.Rom) string { return d.Status }) },
+	},
+	"dat_clone_of": {
+		"Clone Of",
+		"Parent game name when the datfile lists the game as a clone.",
+		func(r rom.RomFile) string { return datValue(r, func(d *dat.Rom) string { return d.Game.CloneOf }) },
+	},
+	"dat_category": {
+		"Category",
+		"Datfile category of the game. example: Games",
+		func(r rom.RomFile) string { return datValue(r, func(d *dat.Rom) string { return d.Game.Category }) },
+	},
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func homebrewValue(r rom.RomFile, value func(*rom.HomebrewHeader) string) string {
+	if r.Homebrew == nil {
+		return ""
+	}
+	return value(r.Homebrew)
+}
+
+func featuresValue(r rom.RomFile, value func(*rom.GameFeatures) string) string {
+	if r.Features == nil {
+		return ""
+	}
+	return value(r.Features)
+}
+
+func diskValue(r rom.RomFile, value func(*rom.DiskInfo) string) string {
+	if r.Disk == nil {
+		return ""
+	}
+	return value(r.Disk)
+}
+
+func datValue(r rom.RomFile, value func(*dat.Rom) string) string {
+	if r.Dat == nil {
+		return ""
+	}
+	return value(r.Dat)
+}
+
+// Whether any of the columns need the ROM to be matched against the datfile
+func NeedsDat(column_ids []string) bool {
+	for _, column_id := range column_ids {
+		if strings.HasPrefix(column_id, "dat_") {
+			return true
+		}
+	}
+	return false
 }
 
 func ValidateColumnIds(column_ids []string) (valid []string, invalid []string) {
@@ -0,0 +1,111 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mroach/rom64/rom"
+)
+
+var (
+	compareHeaderHeaders = []string{"Field", "A", "B"}
+	compareRangeHeaders  = []string{"Offset", "End", "Size", "Percent", "Area"}
+	compareAreaHeaders   = []string{"Area", "Size", "Different", "Percent"}
+)
+
+// Print how two ROMs differ. CSV and tab output only have the differing ranges.
+func PrintComparison(c rom.Comparison, outputFormat string) error {
+	headers := make([][]string, 0, len(c.Headers))
+	for _, h := range c.Headers {
+		headers = append(headers, []string{h.Field, h.A, h.B})
+	}
+
+	ranges := make([][]string, 0, len(c.Ranges))
+	for _, r := range c.Ranges {
+		ranges = append(ranges, []string{
+			fmt.Sprintf("%08X", r.Offset),
+			fmt.Sprintf("%08X", r.End()),
+			strconv.Itoa(r.Size),
+			formatPercent(r.Percent),
+			r.Area,
+		})
+	}
+
+	areas := make([][]string, 0, len(c.Areas))
+	for _, a := range c.Areas {
+		areas = append(areas, []string{a.Area, strconv.Itoa(a.Size), strconv.Itoa(a.Different), formatPercent(a.Percent)})
+	}
+
+	switch outputFormat {
+	case "csv", "tab":
+		w := csv.NewWriter(os.Stdout)
+		if outputFormat == "tab" {
+			w.Comma = '\t'
+		}
+		return writeCsvRecords(w, compareRangeHeaders, ranges)
+	case "json":
+		return PrintJson(c)
+	case "table":
+		fmt.Printf("A: %s\nB: %s\n\n", c.A.File.Path, c.B.File.Path)
+		if c.Identical() {
+			fmt.Println("The ROMs are identical")
+			return nil
+		}
+		if len(headers) > 0 {
+			printTable(compareHeaderHeaders, headers)
+			fmt.Println()
+		}
+		if len(ranges) > 0 {
+			printTable(compareRangeHeaders, ranges)
+			fmt.Println()
+		}
+		printTable(compareAreaHeaders, areas)
+		fmt.Printf("\n%d bytes differ (%s)\n", c.Different, formatPercent(c.Percent))
+		return nil
+	case "text":
+		fmt.Printf("A: %s\nB: %s\n", c.A.File.Path, c.B.File.Path)
+		if c.Identical() {
+			fmt.Println("\nThe ROMs are identical")
+			return nil
+		}
+		if len(headers) > 0 {
+			fmt.Println("\nHeader:")
+			for _, h := range c.Headers {
+				fmt.Printf("  %-16s %s -> %s\n", h.Field, h.A, h.B)
+			}
+		}
+		if len(ranges) > 0 {
+			fmt.Println("\nRanges:")
+			for _, r := range ranges {
+				fmt.Printf("  %s-%s %10s bytes %8s  %s\n", r[0], r[1], r[2], r[3], r[4])
+			}
+		}
+		fmt.Println("\nAreas:")
+		for _, a := range c.Areas {
+			fmt.Printf("  %-7s %10d of %10d bytes differ %8s\n", a.Area, a.Different, a.Size, formatPercent(a.Percent))
+		}
+		fmt.Printf("\n%d bytes differ (%s)\n", c.Different, formatPercent(c.Percent))
+		return nil
+	case "xml":
+		doc := struct {
+			rom.Comparison
+			XMLName struct{} `xml:"comparison"`
+		}{Comparison: c}
+
+		bytes, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s%s\n", xml.Header, bytes)
+		return err
+	}
+
+	return fmt.Errorf("Invalid output format '%s'", outputFormat)
+}
+
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', 3, 64) + "%"
+}
@@ -0,0 +1,80 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mroach/rom64/rom"
+)
+
+var dupeHeaders = []string{"Group", "Action", "Format", "Path", "Datfile Name", "SHA1", "Reason"}
+
+// Print groups of duplicate files. The file to keep is listed first in each group.
+func PrintDupes(groups []rom.DuplicateGroup, outputFormat string) error {
+	records := make([][]string, 0)
+	for i, group := range groups {
+		datName := ""
+		if group.Dat != nil {
+			datName = group.Dat.Name
+		}
+		for _, file := range group.Files {
+			records = append(records, []string{
+				strconv.Itoa(i + 1),
+				file.Action,
+				file.Rom.File.Format.Code,
+				file.Rom.File.Path,
+				datName,
+				group.SHA1,
+				file.Reason,
+			})
+		}
+	}
+
+	switch outputFormat {
+	case "csv", "tab":
+		w := csv.NewWriter(os.Stdout)
+		if outputFormat == "tab" {
+			w.Comma = '\t'
+		}
+		return writeCsvRecords(w, dupeHeaders, records)
+	case "json":
+		return PrintJson(groups)
+	case "table":
+		printTable(dupeHeaders, records)
+		return nil
+	case "text":
+		for _, group := range groups {
+			title := group.SHA1
+			if group.Dat != nil {
+				title = group.Dat.Name
+			}
+			fmt.Println(title)
+			for _, file := range group.Files {
+				fmt.Printf("  %-9s %s", file.Action, file.Rom.File.Path)
+				if file.Reason != "" {
+					fmt.Printf(" (%s)", file.Reason)
+				}
+				fmt.Println()
+			}
+			fmt.Println()
+		}
+		return nil
+	case "xml":
+		doc := struct {
+			Groups  []rom.DuplicateGroup `xml:"group"`
+			XMLName struct{}             `xml:"duplicates"`
+		}{Groups: groups}
+
+		bytes, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s%s\n", xml.Header, bytes)
+		return err
+	}
+
+	return fmt.Errorf("Invalid output format '%s'", outputFormat)
+}
@@ -0,0 +1,53 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"github.com/mroach/rom64/rom"
+)
+
+var headerChangeHeaders = []string{"Field", "Before", "After"}
+
+// Print the header fields an edit changes
+func PrintHeaderChanges(changes []rom.HeaderDifference, outputFormat string) error {
+	records := make([][]string, 0, len(changes))
+	for _, c := range changes {
+		records = append(records, []string{c.Field, c.A, c.B})
+	}
+
+	switch outputFormat {
+	case "csv", "tab":
+		w := csv.NewWriter(os.Stdout)
+		if outputFormat == "tab" {
+			w.Comma = '\t'
+		}
+		return writeCsvRecords(w, headerChangeHeaders, records)
+	case "json":
+		return PrintJson(changes)
+	case "table":
+		printTable(headerChangeHeaders, records)
+		return nil
+	case "text":
+		for _, c := range changes {
+			fmt.Printf("%-16s %s -> %s\n", c.Field, c.A, c.B)
+		}
+		return nil
+	case "xml":
+		doc := struct {
+			Changes []rom.HeaderDifference `xml:"change"`
+			XMLName struct{}               `xml:"changes"`
+		}{Changes: changes}
+
+		bytes, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s%s\n", xml.Header, bytes)
+		return err
+	}
+
+	return fmt.Errorf("Invalid output format '%s'", outputFormat)
+}
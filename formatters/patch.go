@@ -0,0 +1,58 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mroach/rom64/patch"
+)
+
+var patchRegionHeaders = []string{"Offset", "End", "Size"}
+
+// Print the byte ranges a patch changes. Offsets are in hexadecimal except in JSON and XML.
+func PrintPatchRegions(regions []patch.Region, outputFormat string) error {
+	records := make([][]string, 0, len(regions))
+	for _, region := range regions {
+		records = append(records, []string{
+			fmt.Sprintf("%08X", region.Offset),
+			fmt.Sprintf("%08X", region.End()),
+			strconv.Itoa(region.Size),
+		})
+	}
+
+	switch outputFormat {
+	case "csv", "tab":
+		w := csv.NewWriter(os.Stdout)
+		if outputFormat == "tab" {
+			w.Comma = '\t'
+		}
+		return writeCsvRecords(w, patchRegionHeaders, records)
+	case "json":
+		return PrintJson(regions)
+	case "table":
+		printTable(patchRegionHeaders, records)
+		return nil
+	case "text":
+		for _, record := range records {
+			fmt.Printf("%s-%s %s bytes\n", record[0], record[1], record[2])
+		}
+		return nil
+	case "xml":
+		doc := struct {
+			Regions []patch.Region `xml:"region"`
+			XMLName struct{}       `xml:"regions"`
+		}{Regions: regions}
+
+		bytes, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s%s\n", xml.Header, bytes)
+		return err
+	}
+
+	return fmt.Errorf("Invalid output format '%s'", outputFormat)
+}
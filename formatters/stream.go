@@ -0,0 +1,88 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mroach/rom64/rom"
+)
+
+// Prints ROMs one at a time as they become available
+type RomStream interface {
+	Print(rom.RomFile) error
+	Close() error
+}
+
+// Formats that can be printed before all ROMs are known.
+// Tables need every row to size the columns, and JSON and XML wrap everything in one document.
+var StreamingFormats = []string{"csv", "tab", "text"}
+
+func CanStream(outputFormat string) bool {
+	for _, format := range StreamingFormats {
+		if format == outputFormat {
+			return true
+		}
+	}
+	return false
+}
+
+func NewRomStream(outputFormat string, column_ids []string) (RomStream, error) {
+	switch outputFormat {
+	case "csv":
+		return newCsvStream(',', column_ids)
+	case "tab":
+		return newCsvStream('\t', column_ids)
+	case "text":
+		return &textStream{}, nil
+	}
+
+	return nil, fmt.Errorf("Output format '%s' can't be streamed", outputFormat)
+}
+
+type csvStream struct {
+	w          *csv.Writer
+	column_ids []string
+}
+
+func newCsvStream(separator rune, column_ids []string) (*csvStream, error) {
+	w := csv.NewWriter(os.Stdout)
+	w.Comma = separator
+
+	if err := w.Write(ColumnHeaders(column_ids)); err != nil {
+		return nil, err
+	}
+	w.Flush()
+
+	return &csvStream{w, column_ids}, w.Error()
+}
+
+func (s *csvStream) Print(romfile rom.RomFile) error {
+	if err := s.w.Write(PluckRomValues(romfile, s.column_ids)); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvStream) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+type textStream struct {
+	count int
+}
+
+func (s *textStream) Print(romfile rom.RomFile) error {
+	if s.count > 0 {
+		fmt.Println(strings.Repeat("-", 80))
+	}
+	s.count++
+	return PrintText(romfile)
+}
+
+func (s *textStream) Close() error {
+	return nil
+}
@@ -11,14 +11,18 @@ func PrintTable(romfiles []rom.RomFile, column_ids []string) error {
 	headers := ColumnHeaders(column_ids)
 	records := RomsToRecords(romfiles, column_ids)
 
+	printTable(headers, records)
+
+	return nil
+}
+
+func printTable(headers []string, records [][]string) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetColWidth(80)
 	table.SetAutoFormatHeaders(false)
 	table.SetHeader(headers)
 	table.AppendBulk(records)
 	table.Render()
-
-	return nil
 }
 
 var DefaultTableColumns = []string{
@@ -2,21 +2,35 @@ package formatters
 
 import (
 	"os"
+	"strings"
 	"text/template"
 
 	"github.com/mroach/rom64/rom"
 )
 
 var textFormat = `File:
-  Name:    {{.File.Name}}
+  Name:    {{.File.Name}}{{if .File.Archive}}
+  Archive: {{.File.Archive}}
+  Entry:   {{.File.ArchiveEntry}}{{end}}
   Size:    {{.File.Size}} MB
   Format:  {{.File.Format.Code}} ({{.File.Format.Description}})
   Checksums:
     MD5:     {{if .File.MD5}}{{.File.MD5}}{{else}}Not Calculated{{end}}
-    SHA1:    {{if .File.SHA1}}{{.File.SHA1}}{{else}}Not Calculated{{end}}
+    SHA1:    {{if .File.SHA1}}{{.File.SHA1}}{{else}}Not Calculated{{end}}{{if .File.SHA256}}
+    SHA256:  {{.File.SHA256}}{{end}}
+    CRC32:   {{if .File.CRC32}}{{.File.CRC32}}{{else}}Not Calculated{{end}}{{if not .Disk}}
     CRC 1:   {{if .File.CRC1}}{{.File.CRC1}}{{else}}Not Calculated{{end}}
-    CRC 2:   {{if .File.CRC2}}{{.File.CRC2}}{{else}}Not Calculated{{end}}
-
+    CRC 2:   {{if .File.CRC2}}{{.File.CRC2}}{{else}}Not Calculated{{end}}{{end}}
+{{if .Disk}}
+Disk:
+  ID:        {{.Serial}}
+  Media:     {{.MediaFormat.Description}}
+  Region:    {{.Region.Description}}
+  Drive:     {{.Disk.Region.Description}}
+  Version:   {{.Version}}
+  Type:      {{.Disk.Type}}
+  Number:    {{.Disk.DiskNumber}}
+  Company:   {{.Disk.Company}}{{else}}
 ROM:
   ID:        {{.Serial}}
   Title:     {{.ImageName}}
@@ -24,12 +38,31 @@ ROM:
   Region:    {{.Region.Description}}
   Video:     {{.Region.VideoSystem}}
   Version:   1.{{.Version}}
-  CIC:       {{.CIC}}
+  CIC:       {{if .CIC}}{{.CIC}}{{else}}Unknown{{end}} ({{.CICConfidence}} confidence)
+  IPL3 CRC:  {{.IPL3CRC32}}
   CRC 1:     {{.CRC1}}
-  CRC 2:     {{.CRC2}}
-`
+  CRC 2:     {{.CRC2}}{{if .Features}}
+  Save type: {{.Features.SaveType.Description}}
+  Players:   {{.Features.Players}}{{if .Features.Accessories}}
+  Uses:      {{join .Features.Accessories ", "}}{{end}}{{end}}{{if .Homebrew}}
+  Homebrew:  yes
+  Save type: {{.Homebrew.SaveType.Description}}
+  RTC:       {{if .Homebrew.RTC}}yes{{else}}no{{end}}
+  Any region: {{if .Homebrew.RegionFree}}yes{{else}}no{{end}}{{end}}{{end}}{{if .SHA1}}
+  Normalized checksums:
+    MD5:     {{if .MD5}}{{.MD5}}{{else}}Not Calculated{{end}}
+    SHA1:    {{.SHA1}}
+    CRC32:   {{if .CRC32}}{{.CRC32}}{{else}}Not Calculated{{end}}{{end}}
+{{if .Dat}}
+Datfile:
+  Game:      {{.Dat.Game.Name}}
+  Name:      {{.Dat.Name}}
+  Status:    {{if .Dat.Status}}{{.Dat.Status}}{{else}}Not verified{{end}}{{if .Dat.Game.CloneOf}}
+  Clone of:  {{.Dat.Game.CloneOf}}{{end}}{{if .Dat.Game.Category}}
+  Category:  {{.Dat.Game.Category}}{{end}}
+{{end}}`
 
 func PrintText(info rom.RomFile) error {
-	var defaultTextTemplate = template.Must(template.New("rom").Parse(textFormat))
+	var defaultTextTemplate = template.Must(template.New("rom").Funcs(template.FuncMap{"join": strings.Join}).Parse(textFormat))
 	return defaultTextTemplate.Execute(os.Stdout, &info)
 }
@@ -0,0 +1,80 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mroach/rom64/rom"
+)
+
+var validationHeaders = []string{"Status", "Path", "Serial", "SHA1", "Datfile Name", "Message"}
+
+var validationStatusColors = map[string]string{
+	rom.ValidationOK:       "\033[32m",
+	rom.ValidationMismatch: "\033[31m",
+	rom.ValidationUnknown:  "\033[33m",
+	rom.ValidationError:    "\033[31m",
+}
+
+// Print validation results. The text format prints one status line per file.
+func PrintValidation(results []rom.ValidationResult, outputFormat string) error {
+	records := make([][]string, 0, len(results))
+	for _, result := range results {
+		matchName := ""
+		if result.Match != nil {
+			matchName = result.Match.Name
+		}
+		records = append(records, []string{
+			result.Status, result.Path, result.Serial, result.SHA1, matchName, result.Message,
+		})
+	}
+
+	switch outputFormat {
+	case "csv", "tab":
+		w := csv.NewWriter(os.Stdout)
+		if outputFormat == "tab" {
+			w.Comma = '\t'
+		}
+		return writeCsvRecords(w, validationHeaders, records)
+	case "json":
+		return PrintJson(results)
+	case "table":
+		printTable(validationHeaders, records)
+		return nil
+	case "text":
+		for _, result := range results {
+			printValidationLine(result)
+		}
+		return nil
+	case "xml":
+		doc := struct {
+			Results []rom.ValidationResult `xml:"result"`
+			XMLName struct{}               `xml:"validation"`
+		}{Results: results}
+
+		bytes, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s%s\n", xml.Header, bytes)
+		return err
+	}
+
+	return fmt.Errorf("Invalid output format '%s'", outputFormat)
+}
+
+func printValidationLine(result rom.ValidationResult) {
+	status := strings.ToUpper(result.Status)
+	color := validationStatusColors[result.Status]
+	fmt.Printf("%s%-8s\033[0m %s", color, status, result.Path)
+	if result.Match != nil {
+		fmt.Printf(" \"%s\"", result.Match.Name)
+	}
+	fmt.Println()
+	if result.Message != "" {
+		fmt.Printf("%-8s %s\n", "", result.Message)
+	}
+}
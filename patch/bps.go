@@ -0,0 +1,189 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// BPS patches end with the CRC32s of the source, the target, and the patch itself
+const bpsFooterSize = 12
+
+// BPS actions. Each copies length bytes to the target.
+const (
+	// From the source, at the same offset as the target
+	bpsSourceRead = iota
+	// From the patch
+	bpsTargetRead
+	// From anywhere in the source, relative to the last source copy
+	bpsSourceCopy
+	// From earlier in the target, relative to the last target copy
+	bpsTargetCopy
+)
+
+// Metadata and sizes from the start of a BPS patch
+type BPSHeader struct {
+	SourceSize int
+	TargetSize int
+	Metadata   string
+}
+
+func ApplyBPS(source []byte, patch []byte, opts Options) ([]byte, error) {
+	r := patchReader{data: patch, section: "BPS header"}
+	header, err := readBPSHeader(&r)
+	if err != nil {
+		return nil, err
+	}
+	if r.remaining() < bpsFooterSize {
+		return nil, fmt.Errorf("Patch is truncated: BPS footer is missing")
+	}
+
+	footer := patch[len(patch)-bpsFooterSize:]
+	sourceCRC, targetCRC, patchCRC := le32(footer[0:4]), le32(footer[4:8]), le32(footer[8:12])
+
+	if err := checkCRC32("Patch", patchCRC, patch[:len(patch)-4], opts); err != nil {
+		return nil, err
+	}
+	if err := checkCRC32("Source", sourceCRC, source, opts); err != nil {
+		return nil, err
+	}
+	if len(source) != header.SourceSize && !opts.IgnoreChecksums {
+		return nil, fmt.Errorf("Source is %d bytes but the patch is for %d bytes", len(source), header.SourceSize)
+	}
+
+	if header.TargetSize > MAX_TARGET_SIZE {
+		return nil, fmt.Errorf("BPS target size of %d bytes is larger than any ROM", header.TargetSize)
+	}
+
+	target := make([]byte, 0, header.TargetSize)
+	// The actions end where the footer starts
+	r.data = patch[:len(patch)-bpsFooterSize]
+	r.section = "BPS action"
+
+	var sourceOffset, targetOffset int
+	for r.remaining() > 0 {
+		data, err := readBPSNumber(&r)
+		if err != nil {
+			return nil, err
+		}
+		action, length := int(data&3), int(data>>2)+1
+		if len(target)+length > header.TargetSize {
+			return nil, fmt.Errorf("BPS action at offset %d writes past the end of the target", r.pos)
+		}
+
+		switch action {
+		case bpsSourceRead:
+			start := len(target)
+			if start+length > len(source) {
+				return nil, fmt.Errorf("BPS action at offset %d reads past the end of the source", r.pos)
+			}
+			target = append(target, source[start:start+length]...)
+
+		case bpsTargetRead:
+			data, err := r.bytes(length)
+			if err != nil {
+				return nil, err
+			}
+			target = append(target, data...)
+
+		case bpsSourceCopy, bpsTargetCopy:
+			relative, err := readBPSOffset(&r)
+			if err != nil {
+				return nil, err
+			}
+
+			if action == bpsSourceCopy {
+				sourceOffset += relative
+				if sourceOffset < 0 || sourceOffset+length > len(source) {
+					return nil, fmt.Errorf("BPS action at offset %d copies from outside the source", r.pos)
+				}
+				target = append(target, source[sourceOffset:sourceOffset+length]...)
+				sourceOffset += length
+				continue
+			}
+
+			targetOffset += relative
+			if targetOffset < 0 || targetOffset >= len(target) {
+				return nil, fmt.Errorf("BPS action at offset %d copies from outside the target", r.pos)
+			}
+			// The copy can overlap what it's writing, so it goes a byte at a time
+			for i := 0; i < length; i++ {
+				target = append(target, target[targetOffset])
+				targetOffset++
+			}
+		}
+	}
+
+	if len(target) != header.TargetSize {
+		return nil, fmt.Errorf("Patch produced %d bytes but the target should be %d bytes", len(target), header.TargetSize)
+	}
+	if err := checkCRC32("Target", targetCRC, target, opts); err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// Read the sizes and metadata at the start of a BPS patch
+func ReadBPSHeader(patch []byte) (BPSHeader, error) {
+	return readBPSHeader(&patchReader{data: patch, section: "BPS header"})
+}
+
+func readBPSHeader(r *patchReader) (BPSHeader, error) {
+	var header BPSHeader
+
+	if magic, err := r.bytes(len(bpsMagic)); err != nil || !bytes.Equal(magic, bpsMagic) {
+		return header, fmt.Errorf("Not a BPS patch")
+	}
+
+	values := make([]int, 3)
+	for i := range values {
+		n, err := readBPSNumber(r)
+		if err != nil {
+			return header, err
+		}
+		values[i] = int(n)
+	}
+
+	metadata, err := r.bytes(values[2])
+	if err != nil {
+		return header, err
+	}
+
+	header.SourceSize, header.TargetSize, header.Metadata = values[0], values[1], string(metadata)
+	return header, nil
+}
+
+// BPS numbers are 7 bits per byte, least significant first, with the high bit
+// marking the last byte. Each continuation adds one to avoid duplicate encodings.
+func readBPSNumber(r *patchReader) (uint64, error) {
+	var data uint64
+	shift := uint64(1)
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		data += uint64(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return data, nil
+		}
+		shift <<= 7
+		data += shift
+		if shift > 1<<56 {
+			return 0, fmt.Errorf("Invalid BPS number at offset %d", r.pos)
+		}
+	}
+}
+
+// Copy offsets are relative, with the sign in the lowest bit
+func readBPSOffset(r *patchReader) (int, error) {
+	data, err := readBPSNumber(r)
+	if err != nil {
+		return 0, err
+	}
+	offset := int(data >> 1)
+	if data&1 != 0 {
+		offset = -offset
+	}
+	return offset, nil
+}
@@ -0,0 +1,164 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+// Wrap BPS actions in a header and a footer with valid checksums
+func bpsFixture(source []byte, target []byte, actions []byte) []byte {
+	var out bytes.Buffer
+	out.Write(bpsMagic)
+	writeBPSNumber(&out, uint64(len(source)))
+	writeBPSNumber(&out, uint64(len(target)))
+	writeBPSNumber(&out, 0)
+	out.Write(actions)
+
+	footer := make([]byte, 4)
+	for _, data := range [][]byte{source, target} {
+		binary.LittleEndian.PutUint32(footer, crc32.ChecksumIEEE(data))
+		out.Write(footer)
+	}
+	binary.LittleEndian.PutUint32(footer, crc32.ChecksumIEEE(out.Bytes()))
+	out.Write(footer)
+	return out.Bytes()
+}
+
+func TestApplyBPS(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	tests := []struct {
+		name    string
+		target  []byte
+		actions []byte
+	}{
+		// Source read 2, target read 2 "xy", source read 4
+		{"read", []byte("ABxyEFGH"), []byte("\x84\x85xy\x8C")},
+		// Source copy 4 from offset 4, source copy 4 from offset 0
+		{"source copy", []byte("EFGHABCD"), []byte("\x8E\x88\x8E\x91")},
+		// Target read 1 "Z", target copy 5 overlapping from offset 0
+		{"target copy", []byte("ZZZZZZ"), []byte("\x81Z\x93\x80")},
+	}
+	for _, tt := range tests {
+		got, err := ApplyBPS(source, bpsFixture(source, tt.target, tt.actions), Options{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.target) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.target)
+		}
+	}
+}
+
+func TestApplyBPSChecksums(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	patch := bpsFixture(source, []byte("ABxyEFGH"), []byte("\x84\x85xy\x8C"))
+
+	var checksumErr *ChecksumError
+	if _, err := ApplyBPS([]byte("abcdefgh"), patch, Options{}); !errors.As(err, &checksumErr) || checksumErr.Which != "Source" {
+		t.Errorf("wrong source: got %v", err)
+	}
+
+	corrupt := append([]byte(nil), patch...)
+	corrupt[len(corrupt)-bpsFooterSize-2] = 'z'
+	if _, err := ApplyBPS(source, corrupt, Options{}); !errors.As(err, &checksumErr) || checksumErr.Which != "Patch" {
+		t.Errorf("corrupt patch: got %v", err)
+	}
+	if got, err := ApplyBPS(source, corrupt, Options{IgnoreChecksums: true}); err != nil || string(got) != "ABxzEFGH" {
+		t.Errorf("ignoring checksums: got %q, %v", got, err)
+	}
+
+	if crc, ok := SourceCRC32(patch); !ok || crc != crc32.ChecksumIEEE(source) {
+		t.Errorf("source CRC32: got %08X, %t", crc, ok)
+	}
+}
+
+func TestApplyBPSTruncated(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	patch := bpsFixture(source, []byte("ABxyEFGH"), []byte("\x84\x85xy\x8C"))
+	for n := 0; n < len(patch); n++ {
+		if _, err := ApplyBPS(source, patch[:n], Options{IgnoreChecksums: true}); err == nil {
+			t.Errorf("no error for the first %d bytes", n)
+		}
+	}
+}
+
+func TestApplyBPSCorrupt(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	tests := map[string][]byte{
+		"writes past the target": bpsFixture(source, []byte("AB"), []byte("\x8C")),
+		"reads past the source":  bpsFixture(source, make([]byte, 12), []byte("\xAC")),
+		"copies before source":   bpsFixture(source, []byte("ABCD"), []byte("\x8E\x83")),
+		"copies before target":   bpsFixture(source, []byte("ABCD"), []byte("\x80\x87\x82")),
+		"wrong target size":      bpsFixture(source, []byte("ABCDEFGHI"), []byte("\x9C")),
+		"unterminated number":    bpsFixture(source, []byte("AB"), bytes.Repeat([]byte{0x01}, 10)),
+	}
+	for name, patch := range tests {
+		if _, err := ApplyBPS(source, patch, Options{}); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestApplyBPSHugeTarget(t *testing.T) {
+	var out bytes.Buffer
+	out.Write(bpsMagic)
+	writeBPSNumber(&out, 8)
+	writeBPSNumber(&out, 1<<40)
+	writeBPSNumber(&out, 0)
+	out.Write(make([]byte, bpsFooterSize))
+
+	if _, err := ApplyBPS(make([]byte, 8), out.Bytes(), Options{IgnoreChecksums: true}); err == nil {
+		t.Error("no error for a target larger than any ROM")
+	}
+}
+
+func TestBPSNumbers(t *testing.T) {
+	for _, n := range []uint64{0, 1, 127, 128, 129, 16511, 16512, 1 << 32, 1<<56 - 1} {
+		var out bytes.Buffer
+		writeBPSNumber(&out, n)
+		got, err := readBPSNumber(&patchReader{data: out.Bytes()})
+		if err != nil || got != n {
+			t.Errorf("%d: got %d, %v", n, got, err)
+		}
+	}
+	for _, n := range []int{0, 1, -1, 1000, -1000} {
+		var out bytes.Buffer
+		writeBPSOffset(&out, n)
+		got, err := readBPSOffset(&patchReader{data: out.Bytes()})
+		if err != nil || got != n {
+			t.Errorf("offset %d: got %d, %v", n, got, err)
+		}
+	}
+}
+
+func TestCreateBPSRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	source := make([]byte, 0x20000)
+	rng.Read(source)
+
+	for name, target := range roundTripTargets(rng, source) {
+		patch, err := CreateBPS(source, target, "metadata")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		header, err := ReadBPSHeader(patch)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if header.SourceSize != len(source) || header.TargetSize != len(target) || header.Metadata != "metadata" {
+			t.Errorf("%s: got header %+v", name, header)
+		}
+		got, err := Apply(source, patch, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, target) {
+			t.Errorf("%s: the patched data doesn't match the target", name)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// A range of bytes in the target that differs from the source
+type Region struct {
+	Offset int `json:"offset" xml:"offset"`
+	Size   int `json:"size" xml:"size"`
+}
+
+func (r Region) End() int {
+	return r.Offset + r.Size
+}
+
+// IPS offsets are 24 bits, so nothing past 16MB can be patched
+const IPS_MAX_OFFSET = 1<<24 - 1
+
+// Records can't start at this offset since it reads as "EOF"
+var ipsEOFOffset = be24(ipsEOF)
+
+// Runs of the same byte at least this long are encoded as one byte and a repeat
+const minRunLength = 16
+
+// Find where the target differs from the source. Bytes past the end of the
+// source always differ. A shorter target isn't a region; compare the sizes for that.
+func DiffRegions(source []byte, target []byte) []Region {
+	regions := make([]Region, 0)
+	start := -1
+	for i := 0; i < len(target); i++ {
+		same := i < len(source) && source[i] == target[i]
+		if !same && start == -1 {
+			start = i
+		} else if same && start != -1 {
+			regions = append(regions, Region{start, i - start})
+			start = -1
+		}
+	}
+	if start != -1 {
+		regions = append(regions, Region{start, len(target) - start})
+	}
+	return regions
+}
+
+// Create a patch in the given format that turns the source into the target
+func Create(format string, source []byte, target []byte) ([]byte, error) {
+	switch format {
+	case FormatIPS:
+		return CreateIPS(source, target)
+	case FormatBPS:
+		return CreateBPS(source, target, "")
+	}
+	return nil, fmt.Errorf("Can't create %s patches. Use ips or bps.", Formats[format])
+}
+
+// Create an IPS patch. IPS has no checksums and can't reach past 16MB.
+func CreateIPS(source []byte, target []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write(ipsMagic)
+
+	// A record costs more than the few unchanged bytes between two regions
+	for _, region := range MergeRegions(DiffRegions(source, target), 6) {
+		offset, end := region.Offset, region.End()
+		for offset < end {
+			if offset > IPS_MAX_OFFSET {
+				return nil, fmt.Errorf("IPS patches can't change anything past 16MB, but offset %X differs. Use BPS.", offset)
+			}
+			if offset == ipsEOFOffset {
+				// Start a byte earlier, since the byte before is the same or was already written
+				out.Write([]byte{byte((offset - 1) >> 16), byte((offset - 1) >> 8), byte(offset - 1), 0, 2})
+				out.Write(target[offset-1 : offset+1])
+				offset++
+				continue
+			}
+
+			size := end - offset
+			if size > 0xFFFF {
+				size = 0xFFFF
+			}
+
+			if run := runLength(target[offset : offset+size]); run >= minRunLength {
+				out.Write([]byte{byte(offset >> 16), byte(offset >> 8), byte(offset), 0, 0})
+				out.Write([]byte{byte(run >> 8), byte(run), target[offset]})
+				offset += run
+				continue
+			}
+
+			// Stop before the next long run so it gets its own record
+			for i := 1; i < size; i++ {
+				if runLength(target[offset+i:offset+size]) >= minRunLength {
+					size = i
+					break
+				}
+			}
+			out.Write([]byte{byte(offset >> 16), byte(offset >> 8), byte(offset), byte(size >> 8), byte(size)})
+			out.Write(target[offset : offset+size])
+			offset += size
+		}
+	}
+
+	out.Write(ipsEOF)
+	if len(target) < len(source) {
+		if len(target) > IPS_MAX_OFFSET {
+			return nil, fmt.Errorf("IPS patches can't truncate a file to more than 16MB. Use BPS.")
+		}
+		out.Write([]byte{byte(len(target) >> 16), byte(len(target) >> 8), byte(len(target))})
+	}
+	return out.Bytes(), nil
+}
+
+// Create a BPS patch. It carries the CRC32s of the source and target,
+// so applying it to the wrong ROM fails instead of producing garbage.
+func CreateBPS(source []byte, target []byte, metadata string) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write(bpsMagic)
+	writeBPSNumber(&out, uint64(len(source)))
+	writeBPSNumber(&out, uint64(len(target)))
+	writeBPSNumber(&out, uint64(len(metadata)))
+	out.WriteString(metadata)
+
+	action := func(kind int, length int) {
+		writeBPSNumber(&out, uint64(length-1)<<2|uint64(kind))
+	}
+
+	targetOffset := 0
+	pos := 0
+	for _, region := range DiffRegions(source, target) {
+		if region.Offset > pos {
+			action(bpsSourceRead, region.Offset-pos)
+		}
+
+		for pos = region.Offset; pos < region.End(); {
+			if run := runLength(target[pos:region.End()]); run >= minRunLength {
+				// Write the byte once and copy it over itself for the rest of the run
+				action(bpsTargetRead, 1)
+				out.WriteByte(target[pos])
+				action(bpsTargetCopy, run-1)
+				writeBPSOffset(&out, pos-targetOffset)
+				targetOffset = pos + run - 1
+				pos += run
+				continue
+			}
+
+			size := 1
+			for pos+size < region.End() && runLength(target[pos+size:region.End()]) < minRunLength {
+				size++
+			}
+			action(bpsTargetRead, size)
+			out.Write(target[pos : pos+size])
+			pos += size
+		}
+	}
+	if pos < len(target) {
+		action(bpsSourceRead, len(target)-pos)
+	}
+
+	footer := make([]byte, 8)
+	binary.LittleEndian.PutUint32(footer[0:4], crc32.ChecksumIEEE(source))
+	binary.LittleEndian.PutUint32(footer[4:8], crc32.ChecksumIEEE(target))
+	out.Write(footer)
+	binary.LittleEndian.PutUint32(footer[0:4], crc32.ChecksumIEEE(out.Bytes()))
+	out.Write(footer[0:4])
+
+	return out.Bytes(), nil
+}
+
+// Join regions with less than gap unchanged bytes between them
+func MergeRegions(regions []Region, gap int) []Region {
+	merged := make([]Region, 0, len(regions))
+	for _, region := range regions {
+		if n := len(merged); n > 0 && region.Offset-merged[n-1].End() < gap {
+			merged[n-1].Size = region.End() - merged[n-1].Offset
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
+
+// How many times the first byte repeats at the start of data
+func runLength(data []byte) int {
+	for i := 1; i < len(data); i++ {
+		if data[i] != data[0] {
+			return i
+		}
+	}
+	return len(data)
+}
+
+func writeBPSNumber(out *bytes.Buffer, data uint64) {
+	for {
+		b := byte(data & 0x7F)
+		data >>= 7
+		if data == 0 {
+			out.WriteByte(0x80 | b)
+			return
+		}
+		out.WriteByte(b)
+		data--
+	}
+}
+
+func writeBPSOffset(out *bytes.Buffer, offset int) {
+	if offset < 0 {
+		writeBPSNumber(out, uint64(-offset)<<1|1)
+	} else {
+		writeBPSNumber(out, uint64(offset)<<1)
+	}
+}
@@ -0,0 +1,71 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// IPS records are a 3-byte offset and a 2-byte size, followed by the data.
+// A size of 0 is a run of one byte repeated. "EOF" ends the patch, and may be
+// followed by a 3-byte size to truncate the file to.
+var ipsEOF = []byte("EOF")
+
+func ApplyIPS(source []byte, patch []byte) ([]byte, error) {
+	r := patchReader{data: patch, section: "IPS header"}
+	if magic, err := r.bytes(len(ipsMagic)); err != nil || !bytes.Equal(magic, ipsMagic) {
+		return nil, fmt.Errorf("Not an IPS patch")
+	}
+
+	target := append([]byte(nil), source...)
+	for {
+		r.section = "IPS record"
+		offsetBytes, err := r.bytes(3)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(offsetBytes, ipsEOF) {
+			break
+		}
+		offset := be24(offsetBytes)
+
+		sizeBytes, err := r.bytes(2)
+		if err != nil {
+			return nil, err
+		}
+		size := be16(sizeBytes)
+
+		var data []byte
+		if size == 0 {
+			runBytes, err := r.bytes(3)
+			if err != nil {
+				return nil, err
+			}
+			data = bytes.Repeat(runBytes[2:3], be16(runBytes[0:2]))
+		} else if data, err = r.bytes(size); err != nil {
+			return nil, err
+		}
+
+		if end := offset + len(data); end > len(target) {
+			target = append(target, make([]byte, end-len(target))...)
+		}
+		copy(target[offset:], data)
+	}
+
+	// Truncation extension
+	if r.remaining() >= 3 {
+		size, _ := r.bytes(3)
+		if truncate := be24(size); truncate < len(target) {
+			target = target[:truncate]
+		}
+	}
+
+	return target, nil
+}
+
+func be16(b []byte) int {
+	return int(b[0])<<8 | int(b[1])
+}
+
+func be24(b []byte) int {
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+}
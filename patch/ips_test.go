@@ -0,0 +1,106 @@
+package patch
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestApplyIPS(t *testing.T) {
+	source := []byte("ABCDEFGHIJ")
+	tests := []struct {
+		name  string
+		patch []byte
+		want  []byte
+	}{
+		{"record", []byte("PATCH\x00\x00\x02\x00\x02xyEOF"), []byte("ABxyEFGHIJ")},
+		{"run", []byte("PATCH\x00\x00\x04\x00\x00\x00\x03ZEOF"), []byte("ABCDZZZHIJ")},
+		{"extends", []byte("PATCH\x00\x00\x09\x00\x03xyzEOF"), []byte("ABCDEFGHIxyz")},
+		{"truncates", []byte("PATCHEOF\x00\x00\x04"), []byte("ABCD")},
+	}
+	for _, tt := range tests {
+		got, err := ApplyIPS(source, tt.patch)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if !bytes.Equal(source, []byte("ABCDEFGHIJ")) {
+		t.Errorf("the source was changed to %q", source)
+	}
+}
+
+func TestApplyIPSTruncated(t *testing.T) {
+	patch := []byte("PATCH\x00\x00\x02\x00\x02xy\x00\x00\x04\x00\x00\x00\x03ZEOF")
+	for n := 0; n < len(patch); n++ {
+		if _, err := ApplyIPS(make([]byte, 10), patch[:n]); err == nil {
+			t.Errorf("no error for the first %d bytes", n)
+		}
+	}
+}
+
+func TestCreateIPSRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	source := make([]byte, 0x20000)
+	rng.Read(source)
+
+	for name, target := range roundTripTargets(rng, source) {
+		patch, err := CreateIPS(source, target)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := Apply(source, patch, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, target) {
+			t.Errorf("%s: the patched data doesn't match the target", name)
+		}
+	}
+}
+
+func TestCreateIPSAtEOFOffset(t *testing.T) {
+	source := make([]byte, ipsEOFOffset+16)
+	target := append([]byte(nil), source...)
+	target[ipsEOFOffset] = 1
+
+	patch, err := CreateIPS(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ApplyIPS(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Error("the change at the offset that reads as EOF was lost")
+	}
+}
+
+// Targets that exercise each kind of change
+func roundTripTargets(rng *rand.Rand, source []byte) map[string][]byte {
+	changed := append([]byte(nil), source...)
+	for i := 0; i < 50; i++ {
+		offset := rng.Intn(len(changed) - 100)
+		rng.Read(changed[offset : offset+rng.Intn(100)])
+	}
+
+	runs := append([]byte(nil), source...)
+	for i := 0x100; i < 0x1100; i++ {
+		runs[i] = 0xFF
+	}
+
+	longer := append(append([]byte(nil), source...), make([]byte, 0x1000)...)
+	rng.Read(longer[len(source)+0x800:])
+
+	return map[string][]byte{
+		"unchanged": append([]byte(nil), source...),
+		"changed":   changed,
+		"runs":      runs,
+		"longer":    longer,
+		"shorter":   append([]byte(nil), source[:len(source)/2]...),
+	}
+}
@@ -0,0 +1,145 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatIPS    = "ips"
+	FormatBPS    = "bps"
+	FormatVCDIFF = "xdelta"
+)
+
+var Formats = map[string]string{
+	FormatIPS:    "IPS",
+	FormatBPS:    "BPS",
+	FormatVCDIFF: "xdelta (VCDIFF)",
+}
+
+// File extensions for each patch format
+var Extensions = map[string]string{
+	".ips":    FormatIPS,
+	".bps":    FormatBPS,
+	".xdelta": FormatVCDIFF,
+	".vcdiff": FormatVCDIFF,
+}
+
+var (
+	ipsMagic    = []byte("PATCH")
+	bpsMagic    = []byte("BPS1")
+	vcdiffMagic = []byte{0xD6, 0xC3, 0xC4}
+)
+
+// The largest target a patch may build. Cartridges address at most 64MB,
+// and 64DD disk images are smaller, so anything bigger is a corrupt patch.
+const MAX_TARGET_SIZE = 64 * 1024 * 1024
+
+type Options struct {
+	// Apply the patch even when the source or target checksums don't match
+	IgnoreChecksums bool
+}
+
+// Returned when a checksum in the patch doesn't match the data.
+// A source mismatch usually means the patch is for a different ROM or revision.
+type ChecksumError struct {
+	Which    string
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: patch expects %08X, got %08X", e.Which, e.Expected, e.Actual)
+}
+
+// Detect the patch format from its contents
+func Detect(patch []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsMagic):
+		return FormatIPS, nil
+	case bytes.HasPrefix(patch, bpsMagic):
+		return FormatBPS, nil
+	case bytes.HasPrefix(patch, vcdiffMagic):
+		return FormatVCDIFF, nil
+	}
+	return "", fmt.Errorf("Unknown patch format. Supported formats: IPS, BPS, xdelta")
+}
+
+// Detect the patch format from a file name extension
+func FormatFromPath(path string) (string, bool) {
+	format, ok := Extensions[strings.ToLower(filepath.Ext(path))]
+	return format, ok
+}
+
+// Apply a patch of any supported format to the source data and return the patched data.
+// The source isn't changed.
+func Apply(source []byte, patch []byte, opts Options) ([]byte, error) {
+	format, err := Detect(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatIPS:
+		return ApplyIPS(source, patch)
+	case FormatBPS:
+		return ApplyBPS(source, patch, opts)
+	default:
+		return ApplyVCDIFF(source, patch, opts)
+	}
+}
+
+// The CRC32 the patch expects the source to have. Only BPS patches have one.
+func SourceCRC32(patch []byte) (uint32, bool) {
+	if !bytes.HasPrefix(patch, bpsMagic) || len(patch) < len(bpsMagic)+bpsFooterSize {
+		return 0, false
+	}
+	footer := patch[len(patch)-bpsFooterSize:]
+	return le32(footer[0:4]), true
+}
+
+func checkCRC32(which string, expected uint32, data []byte, opts Options) error {
+	if opts.IgnoreChecksums {
+		return nil
+	}
+	if actual := crc32.ChecksumIEEE(data); actual != expected {
+		return &ChecksumError{which, expected, actual}
+	}
+	return nil
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+// Reads through a patch, failing instead of panicking when it ends early
+type patchReader struct {
+	data []byte
+	pos  int
+	// Name of the section being read, for error messages
+	section string
+}
+
+func (r *patchReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *patchReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > r.remaining() {
+		return nil, fmt.Errorf("Patch is truncated: %s needs %d bytes at offset %d", r.section, n, r.pos)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *patchReader) byte() (byte, error) {
+	b, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
@@ -0,0 +1,339 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"hash/adler32"
+)
+
+// VCDIFF (RFC 3284) is the format xdelta3 writes. Patches are split into windows,
+// each building part of the target from a segment of the source (or of the target
+// built so far) with ADD, RUN, and COPY instructions.
+
+// Header indicator bits
+const (
+	vcdDecompress = 0x01
+	vcdCodeTable  = 0x02
+	// xdelta3 extension: the patch carries application data, like file names
+	vcdAppHeader = 0x04
+)
+
+// Window indicator bits
+const (
+	vcdSource = 0x01
+	vcdTarget = 0x02
+	// xdelta3 extension: the window has an Adler-32 of its target data
+	vcdAdler32 = 0x04
+)
+
+const (
+	vcdNoop = iota
+	vcdAdd
+	vcdRun
+	vcdCopy
+)
+
+type vcdInstruction struct {
+	kind byte
+	size int
+	mode byte
+}
+
+// Each opcode is up to two instructions
+type vcdCode [2]vcdInstruction
+
+// Address cache sizes for the default code table
+const (
+	vcdNearSize = 4
+	vcdSameSize = 3
+)
+
+var vcdDefaultCodeTable = buildVCDCodeTable()
+
+// Build the default code table from section 5.6 of RFC 3284
+func buildVCDCodeTable() [256]vcdCode {
+	var table [256]vcdCode
+	i := 0
+	next := func(first, second vcdInstruction) {
+		table[i] = vcdCode{first, second}
+		i++
+	}
+	noop := vcdInstruction{kind: vcdNoop}
+
+	next(vcdInstruction{vcdRun, 0, 0}, noop)
+	for size := 0; size <= 17; size++ {
+		next(vcdInstruction{vcdAdd, size, 0}, noop)
+	}
+	for mode := byte(0); mode < 9; mode++ {
+		next(vcdInstruction{vcdCopy, 0, mode}, noop)
+		for size := 4; size <= 18; size++ {
+			next(vcdInstruction{vcdCopy, size, mode}, noop)
+		}
+	}
+	for mode := byte(0); mode < 6; mode++ {
+		for addSize := 1; addSize <= 4; addSize++ {
+			for copySize := 4; copySize <= 6; copySize++ {
+				next(vcdInstruction{vcdAdd, addSize, 0}, vcdInstruction{vcdCopy, copySize, mode})
+			}
+		}
+	}
+	for mode := byte(6); mode < 9; mode++ {
+		for addSize := 1; addSize <= 4; addSize++ {
+			next(vcdInstruction{vcdAdd, addSize, 0}, vcdInstruction{vcdCopy, 4, mode})
+		}
+	}
+	for mode := byte(0); mode < 9; mode++ {
+		next(vcdInstruction{vcdCopy, 4, mode}, vcdInstruction{vcdAdd, 1, 0})
+	}
+	return table
+}
+
+// Recently used COPY addresses, so nearby addresses can be encoded in fewer bytes
+type vcdAddressCache struct {
+	near     [vcdNearSize]int
+	nextSlot int
+	same     [vcdSameSize * 256]int
+}
+
+func (c *vcdAddressCache) decode(r *patchReader, here int, mode byte) (int, error) {
+	var addr int
+	switch {
+	case mode == 0:
+		n, err := readVCDInteger(r)
+		if err != nil {
+			return 0, err
+		}
+		addr = n
+	case mode == 1:
+		n, err := readVCDInteger(r)
+		if err != nil {
+			return 0, err
+		}
+		addr = here - n
+	case int(mode) < 2+vcdNearSize:
+		n, err := readVCDInteger(r)
+		if err != nil {
+			return 0, err
+		}
+		addr = c.near[mode-2] + n
+	default:
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		addr = c.same[(int(mode)-2-vcdNearSize)*256+int(b)]
+	}
+
+	c.near[c.nextSlot] = addr
+	c.nextSlot = (c.nextSlot + 1) % vcdNearSize
+	c.same[addr%len(c.same)] = addr
+	return addr, nil
+}
+
+func ApplyVCDIFF(source []byte, patch []byte, opts Options) ([]byte, error) {
+	r := patchReader{data: patch, section: "VCDIFF header"}
+
+	magic, err := r.bytes(4)
+	if err != nil || !bytes.Equal(magic[:3], vcdiffMagic) {
+		return nil, fmt.Errorf("Not an xdelta/VCDIFF patch")
+	}
+	if magic[3] != 0 {
+		return nil, fmt.Errorf("Unsupported VCDIFF version %d", magic[3])
+	}
+
+	indicator, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if indicator&vcdDecompress != 0 {
+		return nil, fmt.Errorf("Patches with secondary compression aren't supported. Create the patch with 'xdelta3 -S none'")
+	}
+	if indicator&vcdCodeTable != 0 {
+		return nil, fmt.Errorf("Patches with a custom code table aren't supported")
+	}
+	if indicator&vcdAppHeader != 0 {
+		size, err := readVCDInteger(&r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.bytes(size); err != nil {
+			return nil, err
+		}
+	}
+
+	target := make([]byte, 0, len(source))
+	for window := 0; r.remaining() > 0; window++ {
+		if target, err = applyVCDWindow(&r, source, target, window, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return target, nil
+}
+
+// Decode one window and append what it builds to the target
+func applyVCDWindow(r *patchReader, source []byte, target []byte, window int, opts Options) ([]byte, error) {
+	r.section = fmt.Sprintf("VCDIFF window %d", window)
+
+	indicator, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	// The segment COPY instructions can read from besides the window itself
+	var segment []byte
+	if indicator&(vcdSource|vcdTarget) != 0 {
+		size, err := readVCDInteger(r)
+		if err != nil {
+			return nil, err
+		}
+		position, err := readVCDInteger(r)
+		if err != nil {
+			return nil, err
+		}
+
+		from, name := source, "source"
+		if indicator&vcdTarget != 0 {
+			from, name = target, "target"
+		}
+		if size > len(from) || position > len(from)-size {
+			return nil, fmt.Errorf("%s copies from past the end of the %s", r.section, name)
+		}
+		segment = from[position : position+size]
+	}
+
+	if _, err := readVCDInteger(r); err != nil { // Length of the delta encoding
+		return nil, err
+	}
+	windowSize, err := readVCDInteger(r)
+	if err != nil {
+		return nil, err
+	}
+	if windowSize > MAX_TARGET_SIZE-len(target) {
+		return nil, fmt.Errorf("%s builds a target larger than any ROM", r.section)
+	}
+	deltaIndicator, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if deltaIndicator != 0 {
+		return nil, fmt.Errorf("Patches with secondary compression aren't supported. Create the patch with 'xdelta3 -S none'")
+	}
+
+	sizes := make([]int, 3)
+	for i := range sizes {
+		if sizes[i], err = readVCDInteger(r); err != nil {
+			return nil, err
+		}
+	}
+
+	var checksum uint32
+	if indicator&vcdAdler32 != 0 {
+		b, err := r.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+		checksum = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	}
+
+	sections := make([]patchReader, 3)
+	for i, name := range []string{"data", "instructions", "addresses"} {
+		b, err := r.bytes(sizes[i])
+		if err != nil {
+			return nil, err
+		}
+		sections[i] = patchReader{data: b, section: r.section + " " + name}
+	}
+	data, instructions, addresses := &sections[0], &sections[1], &sections[2]
+
+	start := len(target)
+	var cache vcdAddressCache
+	for instructions.remaining() > 0 {
+		opcode, err := instructions.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, inst := range vcdDefaultCodeTable[opcode] {
+			if inst.kind == vcdNoop {
+				continue
+			}
+
+			size := inst.size
+			if size == 0 {
+				if size, err = readVCDInteger(instructions); err != nil {
+					return nil, err
+				}
+			}
+			if len(target)-start+size > windowSize {
+				return nil, fmt.Errorf("%s writes past the end of the window", r.section)
+			}
+
+			switch inst.kind {
+			case vcdAdd:
+				b, err := data.bytes(size)
+				if err != nil {
+					return nil, err
+				}
+				target = append(target, b...)
+
+			case vcdRun:
+				b, err := data.byte()
+				if err != nil {
+					return nil, err
+				}
+				for i := 0; i < size; i++ {
+					target = append(target, b)
+				}
+
+			case vcdCopy:
+				// Addresses count through the segment and then the window built so far
+				here := len(segment) + len(target) - start
+				addr, err := cache.decode(addresses, here, inst.mode)
+				if err != nil {
+					return nil, err
+				}
+				if addr < 0 || addr >= here {
+					return nil, fmt.Errorf("%s copies from an invalid address %d", r.section, addr)
+				}
+				for i := 0; i < size; i++ {
+					if addr+i < len(segment) {
+						target = append(target, segment[addr+i])
+					} else {
+						target = append(target, target[start+addr+i-len(segment)])
+					}
+				}
+			}
+		}
+	}
+
+	if len(target)-start != windowSize {
+		return nil, fmt.Errorf("%s built %d bytes but should have built %d", r.section, len(target)-start, windowSize)
+	}
+	if indicator&vcdAdler32 != 0 && !opts.IgnoreChecksums {
+		if actual := adler32.Checksum(target[start:]); actual != checksum {
+			return nil, &ChecksumError{fmt.Sprintf("Window %d", window), checksum, actual}
+		}
+	}
+
+	return target, nil
+}
+
+// VCDIFF integers are 7 bits per byte, most significant first,
+// with the high bit set on every byte but the last
+func readVCDInteger(r *patchReader) (int, error) {
+	var n int
+	for i := 0; ; i++ {
+		if i > 8 {
+			return 0, fmt.Errorf("Invalid VCDIFF integer at offset %d", r.pos)
+		}
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<7 | int(b&0x7F)
+		if b&0x80 == 0 {
+			return n, nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"hash/adler32"
+	"strings"
+	"testing"
+)
+
+// Build a VCDIFF patch with one window. The segment is the whole source
+// when segment is true.
+func vcdiffFixture(source []byte, windowSize int, adler []byte, data, instructions, addresses []byte) []byte {
+	indicator := byte(0)
+	var segment []byte
+	if source != nil {
+		indicator |= vcdSource
+		segment = []byte{byte(len(source)), 0}
+	}
+	if adler != nil {
+		indicator |= vcdAdler32
+	}
+
+	delta := []byte{byte(windowSize), 0, byte(len(data)), byte(len(instructions)), byte(len(addresses))}
+	delta = append(delta, adler...)
+	delta = append(delta, data...)
+	delta = append(delta, instructions...)
+	delta = append(delta, addresses...)
+
+	patch := []byte{0xD6, 0xC3, 0xC4, 0x00, 0x00, indicator}
+	patch = append(patch, segment...)
+	patch = append(patch, byte(len(delta)))
+	return append(patch, delta...)
+}
+
+func adlerBytes(data []byte) []byte {
+	sum := adler32.Checksum(data)
+	return []byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)}
+}
+
+func TestApplyVCDIFF(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	tests := []struct {
+		name   string
+		target []byte
+		patch  []byte
+	}{
+		// COPY 2 from 0, ADD 2 "xy", COPY 4 from 4
+		{"copy and add", []byte("ABxyEFGH"),
+			vcdiffFixture(source, 8, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4})},
+		// RUN 5 of "Z"
+		{"run", []byte("ZZZZZ"),
+			vcdiffFixture(nil, 5, nil, []byte("Z"), []byte{0, 5}, nil)},
+		// ADD 1 "Z", COPY 5 overlapping from the start of the window
+		{"overlapping copy", []byte("ZZZZZZ"),
+			vcdiffFixture(nil, 6, nil, []byte("Z"), []byte{2, 21}, []byte{0})},
+		{"checksum", []byte("ABxyEFGH"),
+			vcdiffFixture(source, 8, adlerBytes([]byte("ABxyEFGH")), []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4})},
+	}
+	for _, tt := range tests {
+		got, err := ApplyVCDIFF(source, tt.patch, Options{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.target) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.target)
+		}
+	}
+}
+
+func TestApplyVCDIFFChecksum(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	patch := vcdiffFixture(source, 8, []byte{0, 0, 0, 0}, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4})
+
+	var checksumErr *ChecksumError
+	if _, err := ApplyVCDIFF(source, patch, Options{}); !errors.As(err, &checksumErr) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := ApplyVCDIFF(source, patch, Options{IgnoreChecksums: true}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplyVCDIFFTruncated(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	patch := vcdiffFixture(source, 8, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4})
+	// A patch that ends after the header is valid, it just has no windows
+	for n := 0; n < len(patch); n++ {
+		if _, err := ApplyVCDIFF(source, patch[:n], Options{}); err == nil && n != 5 {
+			t.Errorf("no error for the first %d bytes", n)
+		}
+	}
+}
+
+func TestApplyVCDIFFCorrupt(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	tests := map[string][]byte{
+		"writes past the window": vcdiffFixture(source, 4, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4}),
+		"builds too little":      vcdiffFixture(source, 9, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4}),
+		"copies from the future": vcdiffFixture(source, 8, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 20}),
+		"add past the data":      vcdiffFixture(source, 8, nil, []byte("x"), []byte{19, 2, 3, 20}, []byte{0, 4}),
+		"missing address":        vcdiffFixture(source, 8, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0}),
+		"compressed":             {0xD6, 0xC3, 0xC4, 0x00, vcdDecompress},
+		"custom code table":      {0xD6, 0xC3, 0xC4, 0x00, vcdCodeTable},
+		"unknown version":        {0xD6, 0xC3, 0xC4, 0x01, 0x00},
+	}
+	for name, patch := range tests {
+		if _, err := ApplyVCDIFF(source, patch, Options{}); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	patch := vcdiffFixture(source, 8, nil, []byte("xy"), []byte{19, 2, 3, 20}, []byte{0, 4})
+	if _, err := ApplyVCDIFF(source[:4], patch, Options{}); err == nil {
+		t.Error("segment past the source: no error")
+	}
+}
+
+func TestApplyVCDIFFHugeWindow(t *testing.T) {
+	// A window of 2^34 bytes filled by a RUN of 2^30 bytes
+	window := []byte{0xC0, 0x80, 0x80, 0x80, 0x00}
+	instructions := []byte{0x00, 0x84, 0x80, 0x80, 0x80, 0x00}
+
+	patch := []byte{0xD6, 0xC3, 0xC4, 0x00, 0x00, 0x00, 0x10}
+	patch = append(patch, window...)
+	patch = append(patch, 0x00, 0x01, byte(len(instructions)), 0x00, 'Z')
+	patch = append(patch, instructions...)
+
+	_, err := ApplyVCDIFF(nil, patch, Options{})
+	if err == nil || !strings.Contains(err.Error(), "larger than any ROM") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package rom
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/sevenzip"
+)
+
+var ArchiveExtensions = []string{"zip", "gz", "7z"}
+
+// Separates an archive path from the name of an entry inside it,
+// e.g. "roms/Super Mario 64 (USA).zip#Super Mario 64 (USA).z64"
+const ArchiveEntrySeparator = "#"
+
+// An opened ROM, either a plain file or an entry in an archive
+type romSource struct {
+	io.ReadCloser
+	Size    int64
+	Name    string
+	Archive string
+	Entry   string
+}
+
+// Whether the path looks like an archive based on its extension
+func IsArchive(path string) bool {
+	return hasExtension(path, ArchiveExtensions)
+}
+
+// Build a path that refers to an entry inside an archive
+func ArchiveEntryPath(archive string, entry string) string {
+	return archive + ArchiveEntrySeparator + entry
+}
+
+// Split a path into the archive and the entry inside it.
+// ok is false when the path doesn't refer to an archive entry.
+func SplitArchivePath(fpath string) (archive string, entry string, ok bool) {
+	// A real file always wins, even if it has the separator in its name
+	if _, err := os.Stat(fpath); err == nil {
+		return "", "", false
+	}
+
+	lower := strings.ToLower(fpath)
+	for _, ext := range ArchiveExtensions {
+		marker := "." + ext + ArchiveEntrySeparator
+		if pos := strings.Index(lower, marker); pos != -1 {
+			split := pos + len(marker) - len(ArchiveEntrySeparator)
+			return fpath[:split], fpath[split+len(ArchiveEntrySeparator):], true
+		}
+	}
+
+	return "", "", false
+}
+
+// List the entries in an archive that are probably ROMs based on their extensions.
+// A gzip file only ever has one entry, named after the file without the .gz extension.
+func ListArchiveRoms(archive string) ([]string, error) {
+	entries := make([]string, 0)
+
+	switch strings.ToLower(filepath.Ext(archive)) {
+	case ".gz":
+		entries = append(entries, gzipEntryName(archive))
+	case ".zip":
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return entries, err
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() && HasRomExtension(f.Name) {
+				entries = append(entries, f.Name)
+			}
+		}
+	case ".7z":
+		zr, err := sevenzip.OpenReader(archive)
+		if err != nil {
+			return entries, err
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() && HasRomExtension(f.Name) {
+				entries = append(entries, f.Name)
+			}
+		}
+	default:
+		return entries, fmt.Errorf("Unsupported archive type: %s", archive)
+	}
+
+	return entries, nil
+}
+
+// Size in bytes of a ROM file or archive entry, after decompression
+func ContentSize(fpath string) (int64, error) {
+	src, err := openRom(fpath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return src.Size, nil
+}
+
+// Open a ROM for reading. The path can be a plain file, an archive entry path,
+// or an archive, in which case the first ROM inside it is used.
+func openRom(fpath string) (*romSource, error) {
+	archive, entry, ok := SplitArchivePath(fpath)
+	if !ok && IsArchive(fpath) {
+		entries, err := ListArchiveRoms(fpath)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("No ROM files found in archive '%s'", fpath)
+		}
+		archive, entry, ok = fpath, entries[0], true
+	}
+
+	if !ok {
+		f, err := os.Open(fpath)
+		if err != nil {
+			return nil, err
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &romSource{ReadCloser: f, Size: stat.Size(), Name: stat.Name()}, nil
+	}
+
+	src, err := openArchiveEntry(archive, entry)
+	if err != nil {
+		return nil, err
+	}
+	src.Name = path.Base(entry)
+	src.Archive = archive
+	src.Entry = entry
+	return src, nil
+}
+
+func openArchiveEntry(archive string, entry string) (*romSource, error) {
+	switch strings.ToLower(filepath.Ext(archive)) {
+	case ".gz":
+		return openGzip(archive)
+	case ".zip":
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.Name != entry {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				zr.Close()
+				return nil, err
+			}
+			return &romSource{
+				ReadCloser: &multiCloser{rc, []io.Closer{rc, zr}},
+				Size:       int64(f.UncompressedSize64),
+			}, nil
+		}
+		zr.Close()
+	case ".7z":
+		zr, err := sevenzip.OpenReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.Name != entry {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				zr.Close()
+				return nil, err
+			}
+			return &romSource{
+				ReadCloser: &multiCloser{rc, []io.Closer{rc, zr}},
+				Size:       int64(f.UncompressedSize),
+			}, nil
+		}
+		zr.Close()
+	default:
+		return nil, fmt.Errorf("Unsupported archive type: %s", archive)
+	}
+
+	return nil, fmt.Errorf("Archive '%s' has no entry '%s'", archive, entry)
+}
+
+func openGzip(archive string) (*romSource, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	// The uncompressed size (mod 2^32) is stored in the last 4 bytes
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	trailer := make([]byte, 4)
+	if _, err := f.ReadAt(trailer, stat.Size()-4); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &romSource{
+		ReadCloser: &multiCloser{gz, []io.Closer{gz, f}},
+		Size:       int64(binary.LittleEndian.Uint32(trailer)),
+	}, nil
+}
+
+func gzipEntryName(archive string) string {
+	name := filepath.Base(archive)
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+// Reads from one reader and closes several things, such as an entry and its archive
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (mc *multiCloser) Close() (err error) {
+	for _, c := range mc.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package rom
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mroach/rom64/dat"
+)
+
+// Status of an entry in a collection audit
+const (
+	// File matches a datfile entry
+	AuditHave = "have"
+	// Datfile entry with no matching file
+	AuditMissing = "missing"
+	// File that doesn't match any datfile entry
+	AuditExtra = "extra"
+	// File with the same contents as another file that was already counted
+	AuditDuplicate = "duplicate"
+	// File that couldn't be looked up, such as one without hashes
+	AuditError = "error"
+)
+
+const unknownRegion = "Unknown"
+
+var AuditStatuses = []string{AuditHave, AuditMissing, AuditExtra, AuditDuplicate, AuditError}
+
+type AuditEntry struct {
+	Status string `json:"status" xml:"status"`
+	// Short region name, e.g. USA. From the ROM header for files, from the serial for datfile entries.
+	Region string `json:"region" xml:"region"`
+	// The file. Nil for missing entries.
+	Rom *RomFile `json:"rom,omitempty" xml:"rom,omitempty"`
+	// The datfile entry. Nil for extras.
+	Dat *dat.Rom `json:"dat,omitempty" xml:"dat,omitempty"`
+	// Why the file couldn't be audited. Only set for errors.
+	Message string `json:"message,omitempty" xml:"message,omitempty"`
+}
+
+// Name of the datfile entry, or the file name when there's no match
+func (e *AuditEntry) Name() string {
+	if e.Dat != nil {
+		return e.Dat.Name
+	}
+	if e.Rom != nil {
+		return e.Rom.File.Name
+	}
+	return ""
+}
+
+// Path to the file, or empty for missing entries
+func (e *AuditEntry) Path() string {
+	if e.Rom != nil {
+		return e.Rom.File.Path
+	}
+	return ""
+}
+
+// Counts of each audit status in a region
+type AuditSummary struct {
+	Region    string `json:"region" xml:"name,attr"`
+	Have      int    `json:"have" xml:"have"`
+	Missing   int    `json:"missing" xml:"missing"`
+	Extra     int    `json:"extra" xml:"extra"`
+	Duplicate int    `json:"duplicate" xml:"duplicate"`
+	Error     int    `json:"error" xml:"error"`
+}
+
+type AuditReport struct {
+	Entries []AuditEntry   `json:"entries" xml:"entries>entry"`
+	Regions []AuditSummary `json:"regions" xml:"regions>region"`
+	Total   AuditSummary   `json:"total" xml:"total"`
+}
+
+// Compare a set of ROM files against the datfile.
+//
+// The ROMs must have their normalized hashes calculated. The first file matching
+// each datfile entry counts as "have" and the rest as duplicates. A file matching
+// several entries with the same contents has all of them. Files that match
+// nothing are extras, unless another extra has the same contents. Files without
+// hashes are reported as errors.
+func Audit(roms []RomFile, df dat.DatFile) (report AuditReport, err error) {
+	sorted := make([]RomFile, len(roms))
+	copy(sorted, roms)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].File.Path < sorted[j].File.Path
+	})
+
+	have := make(map[string]bool)
+	seenExtras := make(map[string]bool)
+
+	for i := range sorted {
+		romfile := &sorted[i]
+
+		entry := AuditEntry{Rom: romfile, Region: romfile.Region.Short}
+		if entry.Region == "" {
+			entry.Region = unknownRegion
+		}
+
+		matches, err := romfile.findDatMatches(df)
+		if err != nil {
+			entry.Status = AuditError
+			entry.Message = err.Error()
+			report.Entries = append(report.Entries, entry)
+			continue
+		}
+
+		if len(matches) > 0 {
+			// Report the first entry this file is the first to have
+			entry.Status = AuditDuplicate
+			match := matches[0]
+			for _, m := range matches {
+				key := datKey(m)
+				if have[key] {
+					continue
+				}
+				if entry.Status == AuditDuplicate {
+					entry.Status = AuditHave
+					match = m
+				}
+				have[key] = true
+			}
+			entry.Dat = &match
+			entry.Region = datRegion(match)
+		} else {
+			key := romfile.NormalizedSHA1()
+			if seenExtras[key] {
+				entry.Status = AuditDuplicate
+			} else {
+				entry.Status = AuditExtra
+				seenExtras[key] = true
+			}
+		}
+
+		report.Entries = append(report.Entries, entry)
+	}
+
+	for i := range df.Roms {
+		item := df.Roms[i]
+		if !have[datKey(item)] {
+			report.Entries = append(report.Entries, AuditEntry{
+				Status: AuditMissing,
+				Region: datRegion(item),
+				Dat:    &item,
+			})
+		}
+	}
+
+	report.summarize()
+	return report, nil
+}
+
+func (report *AuditReport) summarize() {
+	byRegion := make(map[string]*AuditSummary)
+	report.Total = AuditSummary{Region: "Total"}
+
+	for _, entry := range report.Entries {
+		summary, ok := byRegion[entry.Region]
+		if !ok {
+			summary = &AuditSummary{Region: entry.Region}
+			byRegion[entry.Region] = summary
+		}
+		summary.add(entry.Status)
+		report.Total.add(entry.Status)
+	}
+
+	report.Regions = make([]AuditSummary, 0, len(byRegion))
+	for _, summary := range byRegion {
+		report.Regions = append(report.Regions, *summary)
+	}
+	sort.Slice(report.Regions, func(i, j int) bool {
+		return report.Regions[i].Region < report.Regions[j].Region
+	})
+}
+
+func (s *AuditSummary) add(status string) {
+	switch status {
+	case AuditHave:
+		s.Have++
+	case AuditMissing:
+		s.Missing++
+	case AuditExtra:
+		s.Extra++
+	case AuditDuplicate:
+		s.Duplicate++
+	case AuditError:
+		s.Error++
+	}
+}
+
+func datKey(item dat.Rom) string {
+	return item.Name + "|" + strings.ToUpper(item.SHA1)
+}
+
+// The region of a datfile entry comes from the last character of its serial
+func datRegion(item dat.Rom) string {
+	if len(item.Serial) == 4 {
+		if region, ok := Regions[strings.ToUpper(item.Serial[3:])]; ok {
+			return region.Short
+		}
+	}
+	return unknownRegion
+}
@@ -0,0 +1,67 @@
+package rom
+
+import (
+	"testing"
+
+	"github.com/mroach/rom64/dat"
+)
+
+func auditDatFile() dat.DatFile {
+	df := dat.DatFile{Roms: []dat.Rom{
+		{Name: "Game (USA).z64", SHA1: "AAAA", Serial: "NGME"},
+		{Name: "Game (USA) (Virtual Console).z64", SHA1: "AAAA", Serial: "NGME"},
+		{Name: "Other (Europe).z64", SHA1: "BBBB", Serial: "NOTP"},
+	}}
+	df.BuildIndex()
+	return df
+}
+
+func auditRom(path string, sha1 string) RomFile {
+	return RomFile{SHA1: sha1, File: FileInfo{Path: path, Name: path, Format: CodeDescription{Code: FormatZ64}}}
+}
+
+func auditStatuses(report AuditReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, entry := range report.Entries {
+		statuses[entry.Name()+"|"+entry.Path()] = entry.Status
+	}
+	return statuses
+}
+
+func TestAuditMarksEveryMatchingEntry(t *testing.T) {
+	roms := []RomFile{auditRom("a.z64", "aaaa"), auditRom("b.z64", "AAAA")}
+
+	report, err := Audit(roms, auditDatFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Total.Have != 1 || report.Total.Duplicate != 1 || report.Total.Missing != 1 {
+		t.Errorf("got %+v", report.Total)
+	}
+	statuses := auditStatuses(report)
+	if s := statuses["Game (USA) (Virtual Console).z64|"]; s != "" {
+		t.Errorf("entry with the same SHA-1 was reported %s", s)
+	}
+	if s := statuses["Other (Europe).z64|"]; s != AuditMissing {
+		t.Errorf("got %s, want missing", s)
+	}
+}
+
+func TestAuditFileWithoutHashes(t *testing.T) {
+	roms := []RomFile{auditRom("nohash.z64", ""), auditRom("b.z64", "BBBB")}
+
+	report, err := Audit(roms, auditDatFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Total.Error != 1 || report.Total.Have != 1 {
+		t.Errorf("got %+v", report.Total)
+	}
+	for _, entry := range report.Entries {
+		if entry.Path() == "nohash.z64" && (entry.Status != AuditError || entry.Message == "") {
+			t.Errorf("got status %s, message '%s'", entry.Status, entry.Message)
+		}
+	}
+}
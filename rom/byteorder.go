@@ -0,0 +1,241 @@
+package rom
+
+import (
+	"fmt"
+	"io"
+)
+
+// Default buffer size for byte order conversion. Large enough to keep the
+// number of reads on big ROMs down, and a multiple of every word size.
+const ByteOrderBufferSize = 1024 * 1024
+
+// A function that converts whole words in-place
+type wordSwapper func([]byte)
+
+// Returns the in-place swap function and word size needed to convert data
+// between two ROM formats. A nil function means no conversion is needed.
+func byteOrderSwapper(fromFormat, toFormat string) (wordSwapper, int, error) {
+	for _, format := range []string{fromFormat, toFormat} {
+		if _, ok := FileFormats[format]; !ok {
+			return nil, 0, fmt.Errorf("Unknown ROM format '%s'", format)
+		}
+	}
+
+	if fromFormat == toFormat {
+		return nil, 1, nil
+	}
+
+	// Every swap is its own inverse, so the direction doesn't matter
+	pair := map[string]bool{fromFormat: true, toFormat: true}
+	switch {
+	case pair[FormatZ64] && pair[FormatV64]:
+		return swap16, 2, nil
+	case pair[FormatZ64] && pair[FormatN64]:
+		return swap32, 4, nil
+	default: // v64 <-> n64
+		return swapHalfwords, 4, nil
+	}
+}
+
+// Converts buf from one ROM format to another in place. Any trailing bytes
+// that don't make up a whole word are left untouched.
+func SwapByteOrder(buf []byte, fromFormat, toFormat string) error {
+	swap, size, err := byteOrderSwapper(fromFormat, toFormat)
+	if err != nil {
+		return err
+	}
+	if swap != nil {
+		swap(buf[:len(buf)-len(buf)%size])
+	}
+	return nil
+}
+
+// AB CD => BA DC
+func swap16(buf []byte) {
+	for i := 0; i+1 < len(buf); i += 2 {
+		buf[i], buf[i+1] = buf[i+1], buf[i]
+	}
+}
+
+// AB CD => DC BA
+func swap32(buf []byte) {
+	for i := 0; i+3 < len(buf); i += 4 {
+		buf[i], buf[i+1], buf[i+2], buf[i+3] = buf[i+3], buf[i+2], buf[i+1], buf[i]
+	}
+}
+
+// AB CD => CD AB
+func swapHalfwords(buf []byte) {
+	for i := 0; i+3 < len(buf); i += 4 {
+		buf[i], buf[i+1], buf[i+2], buf[i+3] = buf[i+2], buf[i+3], buf[i], buf[i+1]
+	}
+}
+
+// Reads ROM data in one byte order and returns it in another.
+//
+// Words are swapped in place in an internal buffer. When the underlying reader
+// returns a number of bytes that isn't a multiple of the word size, the partial
+// word is carried over to the next read. A partial word at the end of the
+// stream is returned as-is.
+type ByteOrderReader struct {
+	r    io.Reader
+	swap wordSwapper
+	word int
+	buf  []byte
+	pos  int // start of converted data not yet returned
+	end  int // end of converted data. buf[end:raw] is an unconverted partial word.
+	raw  int
+	err  error
+}
+
+// Create a ByteOrderReader that converts from fromFormat to toFormat
+func NewByteOrderReader(r io.Reader, fromFormat, toFormat string) (*ByteOrderReader, error) {
+	return NewByteOrderReaderSize(r, fromFormat, toFormat, ByteOrderBufferSize)
+}
+
+// Create a ByteOrderReader with a specific buffer size. The reader never reads
+// more than size bytes ahead of what has been returned, which is useful when
+// only the start of a file is needed.
+func NewByteOrderReaderSize(r io.Reader, fromFormat, toFormat string, size int) (*ByteOrderReader, error) {
+	swap, word, err := byteOrderSwapper(fromFormat, toFormat)
+	if err != nil {
+		return nil, err
+	}
+	if size < word {
+		size = word
+	}
+
+	return &ByteOrderReader{
+		r:    r,
+		swap: swap,
+		word: word,
+		buf:  make([]byte, size-size%word),
+	}, nil
+}
+
+// Fill the buffer with converted data. Returns false when there's nothing left.
+func (br *ByteOrderReader) fill() bool {
+	for br.pos == br.end {
+		if br.err != nil {
+			// Flush a trailing partial word as-is
+			if br.end < br.raw {
+				br.end = br.raw
+				return true
+			}
+			return false
+		}
+
+		partial := copy(br.buf, br.buf[br.end:br.raw])
+		n, err := br.r.Read(br.buf[partial:])
+		br.err = err
+
+		br.raw = partial + n
+		br.pos = 0
+		br.end = br.raw - br.raw%br.word
+		if br.swap != nil {
+			br.swap(br.buf[:br.end])
+		}
+	}
+	return true
+}
+
+func (br *ByteOrderReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !br.fill() {
+		return 0, br.err
+	}
+
+	n := copy(p, br.buf[br.pos:br.end])
+	br.pos += n
+	return n, nil
+}
+
+// Implements io.WriterTo so io.Copy writes straight from the internal buffer
+func (br *ByteOrderReader) WriteTo(w io.Writer) (written int64, err error) {
+	for br.fill() {
+		n, err := w.Write(br.buf[br.pos:br.end])
+		written += int64(n)
+		br.pos += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	if br.err == io.EOF {
+		return written, nil
+	}
+	return written, br.err
+}
+
+// Writes ROM data in one byte order to an underlying writer in another.
+//
+// Data is copied to an internal buffer before being swapped, so the caller's
+// slice is never modified. Partial words are held until the next Write.
+// Call Flush when done to write any buffered data.
+type ByteOrderWriter struct {
+	w    io.Writer
+	swap wordSwapper
+	word int
+	buf  []byte
+	n    int
+}
+
+// Create a ByteOrderWriter that converts from fromFormat to toFormat
+func NewByteOrderWriter(w io.Writer, fromFormat, toFormat string) (*ByteOrderWriter, error) {
+	swap, word, err := byteOrderSwapper(fromFormat, toFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ByteOrderWriter{
+		w:    w,
+		swap: swap,
+		word: word,
+		buf:  make([]byte, ByteOrderBufferSize),
+	}, nil
+}
+
+func (bw *ByteOrderWriter) Write(p []byte) (written int, err error) {
+	for len(p) > 0 {
+		n := copy(bw.buf[bw.n:], p)
+		bw.n += n
+		written += n
+		p = p[n:]
+
+		if bw.n == len(bw.buf) {
+			if err := bw.writeWords(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Write all whole words in the buffer, keeping a trailing partial word
+func (bw *ByteOrderWriter) writeWords() error {
+	whole := bw.n - bw.n%bw.word
+	if bw.swap != nil {
+		bw.swap(bw.buf[:whole])
+	}
+	if _, err := bw.w.Write(bw.buf[:whole]); err != nil {
+		return err
+	}
+	bw.n = copy(bw.buf, bw.buf[whole:bw.n])
+	return nil
+}
+
+// Write all buffered data. A trailing partial word is written as-is.
+func (bw *ByteOrderWriter) Flush() error {
+	if err := bw.writeWords(); err != nil {
+		return err
+	}
+	if bw.n > 0 {
+		if _, err := bw.w.Write(bw.buf[:bw.n]); err != nil {
+			return err
+		}
+		bw.n = 0
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package rom
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+var formatPairs = [][2]string{
+	{FormatZ64, FormatV64},
+	{FormatZ64, FormatN64},
+	{FormatV64, FormatN64},
+	{FormatV64, FormatZ64},
+	{FormatN64, FormatZ64},
+	{FormatN64, FormatV64},
+	{FormatZ64, FormatZ64},
+}
+
+var byteOrderSignatures = map[string][]byte{
+	FormatZ64: bomZ64,
+	FormatV64: bomV64,
+	FormatN64: bomN64,
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestSwapByteOrderSignatures(t *testing.T) {
+	for _, pair := range formatPairs {
+		buf := append([]byte(nil), byteOrderSignatures[pair[0]]...)
+		if err := SwapByteOrder(buf, pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, byteOrderSignatures[pair[1]]) {
+			t.Errorf("%s -> %s: got % X, want % X", pair[0], pair[1], buf, byteOrderSignatures[pair[1]])
+		}
+	}
+}
+
+func TestSwapByteOrderRoundTrip(t *testing.T) {
+	original := randomData(4096)
+	for _, pair := range formatPairs {
+		buf := append([]byte(nil), original...)
+		if err := SwapByteOrder(buf, pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+		if err := SwapByteOrder(buf, pair[1], pair[0]); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, original) {
+			t.Errorf("%s -> %s -> %s didn't give back the original", pair[0], pair[1], pair[0])
+		}
+	}
+}
+
+func TestSwapByteOrderPartialWord(t *testing.T) {
+	buf := []byte{1, 2, 3, 4, 5, 6, 7}
+	if err := SwapByteOrder(buf, FormatZ64, FormatN64); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{4, 3, 2, 1, 5, 6, 7}; !bytes.Equal(buf, want) {
+		t.Errorf("got % X, want % X", buf, want)
+	}
+
+	buf = []byte{1, 2, 3}
+	if err := SwapByteOrder(buf, FormatZ64, FormatV64); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{2, 1, 3}; !bytes.Equal(buf, want) {
+		t.Errorf("got % X, want % X", buf, want)
+	}
+}
+
+func TestSwapByteOrderUnknownFormat(t *testing.T) {
+	if err := SwapByteOrder(make([]byte, 4), FormatZ64, "ndd"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := NewByteOrderReader(bytes.NewReader(nil), "rom", FormatZ64); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+// Reading should give the same result as swapping the whole buffer, however the
+// underlying reader splits the data and whatever the buffer size.
+func TestByteOrderReader(t *testing.T) {
+	for _, size := range []int{0, 1, 3, 4, 5, 1023, 4096, 4099} {
+		original := randomData(size)
+		for _, pair := range formatPairs {
+			want := append([]byte(nil), original...)
+			SwapByteOrder(want, pair[0], pair[1])
+
+			readers := map[string]func() io.Reader{
+				"whole":    func() io.Reader { return bytes.NewReader(original) },
+				"one byte": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(original)) },
+				"half":     func() io.Reader { return iotest.HalfReader(bytes.NewReader(original)) },
+			}
+			for name, newReader := range readers {
+				for _, bufSize := range []int{1, 6, 64, ByteOrderBufferSize} {
+					br, err := NewByteOrderReaderSize(newReader(), pair[0], pair[1], bufSize)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := io.ReadAll(iotest.OneByteReader(br))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(got, want) {
+						t.Errorf("%d bytes %s -> %s, %s reader, buffer %d: output differs", size, pair[0], pair[1], name, bufSize)
+					}
+				}
+			}
+
+			br, err := NewByteOrderReader(iotest.HalfReader(bytes.NewReader(original)), pair[0], pair[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if _, err := io.Copy(&out, br); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("%d bytes %s -> %s with WriteTo: output differs", size, pair[0], pair[1])
+			}
+		}
+	}
+}
+
+func TestByteOrderReaderError(t *testing.T) {
+	br, err := NewByteOrderReader(iotest.TimeoutReader(bytes.NewReader(randomData(16))), FormatZ64, FormatV64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(br); err != iotest.ErrTimeout {
+		t.Errorf("got %v, want %v", err, iotest.ErrTimeout)
+	}
+}
+
+func TestByteOrderWriter(t *testing.T) {
+	original := randomData(ByteOrderBufferSize + 7)
+	for _, pair := range formatPairs {
+		want := append([]byte(nil), original...)
+		SwapByteOrder(want, pair[0], pair[1])
+
+		var out bytes.Buffer
+		bw, err := NewByteOrderWriter(&out, pair[0], pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := append([]byte(nil), original...)
+		// Odd-sized writes split words, then the rest wraps the buffer
+		for i := 0; i < 99; i += 3 {
+			if _, err := bw.Write(input[i : i+3]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := bw.Write(input[99:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := bw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(input, original) {
+			t.Errorf("%s -> %s: the written slice was changed", pair[0], pair[1])
+		}
+		if !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("%s -> %s: output differs", pair[0], pair[1])
+		}
+	}
+}
+
+func BenchmarkSwapByteOrder(b *testing.B) {
+	data := randomData(8 * 1024 * 1024)
+	for _, pair := range formatPairs[:3] {
+		b.Run(pair[0]+"-"+pair[1], func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				SwapByteOrder(data, pair[0], pair[1])
+			}
+		})
+	}
+}
+
+func BenchmarkByteOrderReader(b *testing.B) {
+	data := randomData(8 * 1024 * 1024)
+	for _, pair := range formatPairs[:3] {
+		b.Run(pair[0]+"-"+pair[1], func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				br, _ := NewByteOrderReader(bytes.NewReader(data), pair[0], pair[1])
+				if _, err := io.Copy(io.Discard, br); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Small reads, like a hash reading through a bufio.Reader
+func BenchmarkByteOrderReaderSmallReads(b *testing.B) {
+	data := randomData(8 * 1024 * 1024)
+	buf := make([]byte, 4096)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		br, _ := NewByteOrderReader(bytes.NewReader(data), FormatV64, FormatZ64)
+		for {
+			if _, err := br.Read(buf); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package rom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Bump when the way hashes or CRCs are calculated changes so old entries are thrown away
+const HASH_CACHE_VERSION = 2
+
+// Hashes and CRCs calculated for a file, stored between runs.
+// Empty values haven't been calculated.
+type CacheEntry struct {
+	// Identifies the version of the file the hashes belong to
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Inode   uint64 `json:"inode,omitempty"`
+
+	MD5    string `json:"md5,omitempty"`
+	SHA1   string `json:"sha1,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	CRC32  string `json:"crc32,omitempty"`
+	CRC1   string `json:"crc1,omitempty"`
+	CRC2   string `json:"crc2,omitempty"`
+	// The CIC found while calculating the CRCs of a ROM with unknown bootcode.
+	// Empty when no CIC matched.
+	CIC      string `json:"cic,omitempty"`
+	RomMD5   string `json:"rom_md5,omitempty"`
+	RomSHA1  string `json:"rom_sha1,omitempty"`
+	RomCRC32 string `json:"rom_crc32,omitempty"`
+}
+
+type hashCacheFile struct {
+	Version int                   `json:"version"`
+	Entries map[string]CacheEntry `json:"entries"`
+}
+
+// On-disk cache of file hashes keyed by absolute path. An entry is only used
+// while the size, modification time, and inode of the file are unchanged.
+// ROMs inside archives are keyed by their archive entry path and checked
+// against the archive file.
+//
+// Safe for concurrent use. Changes are only written by Save.
+type HashCache struct {
+	Path    string
+	mu      sync.Mutex
+	entries map[string]CacheEntry
+	dirty   bool
+}
+
+type HashCacheStats struct {
+	Path string `json:"path" xml:"path"`
+	// Size of the cache file in bytes
+	Size    int64 `json:"size" xml:"size"`
+	Entries int   `json:"entries" xml:"entries"`
+	// Entries for files that are gone or have changed
+	Stale int `json:"stale" xml:"stale"`
+}
+
+// Default location of the hash cache, under $XDG_CACHE_HOME on Linux
+// and the platform's cache directory elsewhere.
+func DefaultHashCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "rom64", "hashes.json"), nil
+}
+
+// Load the cache at the given path. A missing file, or one written by
+// a different version of the cache, is an empty cache.
+func OpenHashCache(path string) (*HashCache, error) {
+	cache := &HashCache{Path: path, entries: make(map[string]CacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, err
+	}
+
+	var contents hashCacheFile
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return cache, err
+	}
+	if contents.Version == HASH_CACHE_VERSION && contents.Entries != nil {
+		cache.entries = contents.Entries
+	}
+
+	return cache, nil
+}
+
+// Write the cache to disk if anything changed. The file is replaced in one step
+// so an interrupted write can't leave a broken cache behind.
+func (c *HashCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(hashCacheFile{Version: HASH_CACHE_VERSION, Entries: c.entries})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), ".hashes-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// Same as CalcHashesContext, but hashes found in the cache aren't calculated again
+// and newly calculated ones are added to it. A nil cache calculates everything.
+func (c *HashCache) CalcHashes(ctx context.Context, romfile *RomFile, progress io.Writer, hashes ...string) error {
+	if c == nil {
+		return romfile.CalcHashesContext(ctx, progress, hashes...)
+	}
+
+	key, stamp, err := cacheKey(romfile.File.Path)
+	if err != nil {
+		return romfile.CalcHashesContext(ctx, progress, hashes...)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if !ok || !entry.sameFile(stamp) {
+		entry = stamp
+	}
+
+	missing := entry.apply(romfile, hashes)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := romfile.CalcHashesContext(ctx, progress, missing...); err != nil {
+		return err
+	}
+	entry.store(romfile, missing)
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.dirty = true
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *HashCache) Stats() (HashCacheStats, error) {
+	stats := HashCacheStats{Path: c.Path}
+
+	if stat, err := os.Stat(c.Path); err == nil {
+		stats.Size = stat.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return stats, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats.Entries = len(c.entries)
+	stats.Stale = len(c.staleKeys())
+	return stats, nil
+}
+
+// Remove entries for files that are gone or have changed.
+// Returns the paths that were removed.
+func (c *HashCache) Prune() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.staleKeys()
+	for _, key := range removed {
+		delete(c.entries, key)
+	}
+	if len(removed) > 0 {
+		c.dirty = true
+	}
+	return removed
+}
+
+// Remove every entry and delete the cache file
+func (c *HashCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]CacheEntry)
+	c.dirty = false
+
+	if err := os.Remove(c.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (c *HashCache) staleKeys() []string {
+	stale := make([]string, 0)
+	for key, entry := range c.entries {
+		_, stamp, err := cacheKey(key)
+		if err != nil || !entry.sameFile(stamp) {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// The cache key of a ROM path and the current size, modification time,
+// and inode of the file on disk. For archive entries that's the archive.
+func cacheKey(fpath string) (string, CacheEntry, error) {
+	var stamp CacheEntry
+
+	diskPath, entry := fpath, ""
+	if archive, archiveEntry, ok := SplitArchivePath(fpath); ok {
+		diskPath, entry = archive, archiveEntry
+	}
+
+	diskPath, err := filepath.Abs(diskPath)
+	if err != nil {
+		return "", stamp, err
+	}
+
+	stat, err := os.Stat(diskPath)
+	if err != nil {
+		return "", stamp, err
+	}
+
+	stamp.Size = stat.Size()
+	stamp.ModTime = stat.ModTime().UnixNano()
+	stamp.Inode = fileInode(stat)
+
+	key := diskPath
+	if entry != "" {
+		key = ArchiveEntryPath(diskPath, entry)
+	}
+	return key, stamp, nil
+}
+
+func (e *CacheEntry) sameFile(other CacheEntry) bool {
+	return e.Size == other.Size && e.ModTime == other.ModTime && e.Inode == other.Inode
+}
+
+// Copy cached values to the ROM. Returns the hashes that aren't cached.
+func (e *CacheEntry) apply(romfile *RomFile, hashes []string) (missing []string) {
+	for _, name := range hashes {
+		cached, calculated := e.fields(romfile, name)
+		if len(cached) == 0 || *cached[0] == "" {
+			missing = append(missing, name)
+			continue
+		}
+		for i := range cached {
+			*calculated[i] = *cached[i]
+		}
+		if name == HashN64CRC && romfile.CICConfidence == CICConfidenceLow && romfile.CIC != "" {
+			cic, ok := cicFromName(romfile.CIC)
+			if !ok {
+				romfile.CIC = ""
+				missing = append(missing, name)
+				continue
+			}
+			romfile.CICConfidence = CICConfidenceMedium
+			romfile.foundCIC = &cic
+		}
+	}
+	return missing
+}
+
+// Copy calculated values from the ROM into the entry
+func (e *CacheEntry) store(romfile *RomFile, hashes []string) {
+	for _, name := range hashes {
+		cached, calculated := e.fields(romfile, name)
+		for i := range cached {
+			*cached[i] = *calculated[i]
+		}
+	}
+}
+
+// Where a hash is kept in the entry and in the ROM. Unknown hashes aren't cached.
+func (e *CacheEntry) fields(romfile *RomFile, name string) (cached, calculated []*string) {
+	switch name {
+	case HashMD5:
+		return []*string{&e.MD5}, []*string{&romfile.File.MD5}
+	case HashSHA1:
+		return []*string{&e.SHA1}, []*string{&romfile.File.SHA1}
+	case HashSHA256:
+		return []*string{&e.SHA256}, []*string{&romfile.File.SHA256}
+	case HashCRC32:
+		return []*string{&e.CRC32}, []*string{&romfile.File.CRC32}
+	case HashN64CRC:
+		// An unknown CIC is found while calculating the CRCs, so it's cached with them
+		if romfile.CICConfidence == CICConfidenceLow || romfile.foundCIC != nil {
+			return []*string{&e.CRC1, &e.CRC2, &e.CIC}, []*string{&romfile.File.CRC1, &romfile.File.CRC2, &romfile.CIC}
+		}
+		// CRC1 and CRC2 are always calculated together
+		return []*string{&e.CRC1, &e.CRC2}, []*string{&romfile.File.CRC1, &romfile.File.CRC2}
+	case HashRomMD5:
+		return []*string{&e.RomMD5}, []*string{&romfile.MD5}
+	case HashRomSHA1:
+		return []*string{&e.RomSHA1}, []*string{&romfile.SHA1}
+	case HashRomCRC32:
+		return []*string{&e.RomCRC32}, []*string{&romfile.CRC32}
+	}
+	return nil, nil
+}
@@ -0,0 +1,72 @@
+package rom
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A ROM with unknown bootcode whose header CRCs were made with the given CIC
+func writeUnknownCICRom(t *testing.T, cic CICType) string {
+	data := make([]byte, CRC_CHECKSUM_END)
+	rand.New(rand.NewSource(3)).Read(data[ROM_HEADER_SIZE:])
+
+	crc1, crc2 := calcCRC(data, cic)
+	header := Header{CRC1: crc1, CRC2: crc2, MediaFormat: 'N', RegionCode: 'E'}
+	copy(header.CartridgeId[:], "XX")
+	headerData, err := header.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data, headerData)
+
+	path := filepath.Join(t.TempDir(), "unknown.z64")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHashCacheKeepsFoundCIC(t *testing.T) {
+	want := CICTypes["6105"]
+	path := writeUnknownCICRom(t, want)
+	cache, err := OpenHashCache(filepath.Join(t.TempDir(), "hashes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := FromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.CICConfidence != CICConfidenceLow {
+		t.Fatalf("the bootcode was recognized as %s", first.CIC)
+	}
+	if err := cache.CalcHashes(context.Background(), &first, nil, HashN64CRC); err != nil {
+		t.Fatal(err)
+	}
+	if first.CIC != want.Name || first.CICConfidence != CICConfidenceMedium {
+		t.Fatalf("found %s with %s confidence", first.CIC, first.CICConfidence)
+	}
+
+	second, err := FromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read bytes.Buffer
+	if err := cache.CalcHashes(context.Background(), &second, &read, HashN64CRC); err != nil {
+		t.Fatal(err)
+	}
+	if read.Len() != 0 {
+		t.Errorf("read %d bytes instead of using the cache", read.Len())
+	}
+	if second.CIC != want.Name || second.CICConfidence != CICConfidenceMedium || second.cicType() != want {
+		t.Errorf("got %s with %s confidence from the cache", second.CIC, second.CICConfidence)
+	}
+	if second.File.CRC1 != first.File.CRC1 || second.File.CRC2 != first.File.CRC2 {
+		t.Errorf("got CRCs %s %s from the cache, want %s %s", second.File.CRC1, second.File.CRC2, first.File.CRC1, first.File.CRC2)
+	}
+}
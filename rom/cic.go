@@ -0,0 +1,214 @@
+package rom
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+)
+
+// How sure the CIC detection is
+const (
+	// The IPL3 bootcode is a known one
+	CICConfidenceHigh = "high"
+	// Found by a signature in the bootcode, or by the seed that makes the header CRCs match
+	CICConfidenceMedium = "medium"
+	// Unknown bootcode. The CRCs are calculated as for a 6102, the most common CIC.
+	CICConfidenceLow = "low"
+)
+
+// Variations of the checksum calculation in the IPL3 bootcode.
+// CICs that share an algorithm only differ in their seed.
+type crcAlgorithm int
+
+const (
+	crcAlgorithm6102 crcAlgorithm = iota
+	crcAlgorithm6103
+	crcAlgorithm6105
+	crcAlgorithm6106
+)
+
+var crcAlgorithms = []crcAlgorithm{crcAlgorithm6102, crcAlgorithm6103, crcAlgorithm6105, crcAlgorithm6106}
+
+// The CIC each algorithm is named after
+var crcAlgorithmNames = map[crcAlgorithm]string{
+	crcAlgorithm6102: "6102",
+	crcAlgorithm6103: "6103",
+	crcAlgorithm6105: "6105",
+	crcAlgorithm6106: "6106",
+}
+
+type CICType struct {
+	Name        string
+	Description string
+	// The seed the CIC hands to the IPL3, which the CRC calculation starts from
+	Seed      byte
+	algorithm crcAlgorithm
+}
+
+// The starting value of the CRC calculation
+func (c CICType) crcSeed() uint32 {
+	return crcSeed(c.Seed, c.algorithm)
+}
+
+func crcSeed(seed byte, algorithm crcAlgorithm) uint32 {
+	switch algorithm {
+	case crcAlgorithm6103, crcAlgorithm6106:
+		return 0x6C078965*uint32(seed) + 1
+	default:
+		return 0x5D588B65*uint32(seed) + 1
+	}
+}
+
+var CICTypes = map[string]CICType{
+	"5101":      {"5101", "CIC-NUS-5101 (Aleck64)", 0xAC, crcAlgorithm6102},
+	"6101":      {"6101", "CIC-NUS-6101", 0x3F, crcAlgorithm6102},
+	"6102":      {"6102", "CIC-NUS-6102", 0x3F, crcAlgorithm6102},
+	"6103":      {"6103", "CIC-NUS-6103", 0x78, crcAlgorithm6103},
+	"6105":      {"6105", "CIC-NUS-6105", 0x91, crcAlgorithm6105},
+	"6106":      {"6106", "CIC-NUS-6106", 0x85, crcAlgorithm6106},
+	"7101":      {"7101", "CIC-NUS-7101 (PAL 6102)", 0x3F, crcAlgorithm6102},
+	"7102":      {"7102", "CIC-NUS-7102 (PAL 6101)", 0x3F, crcAlgorithm6102},
+	"7103":      {"7103", "CIC-NUS-7103 (PAL 6103)", 0x78, crcAlgorithm6103},
+	"7105":      {"7105", "CIC-NUS-7105 (PAL 6105)", 0x91, crcAlgorithm6105},
+	"7106":      {"7106", "CIC-NUS-7106 (PAL 6106)", 0x85, crcAlgorithm6106},
+	"8303":      {"8303", "CIC-NUS-8303 (64DD IPL)", 0xDD, crcAlgorithm6102},
+	"libdragon": {"libdragon", "libdragon open-source IPL3 (6102 compatible)", 0x3F, crcAlgorithm6102},
+}
+
+// CRC32 of the IPL3 bootcode at 0x40-0x1000
+var ipl3Checksums = map[uint32]string{
+	0x587BD543: "5101",
+	0x6170A4A1: "6101",
+	0x90BB6CB5: "6102",
+	0x0B050EE0: "6103",
+	0x98BC2C86: "6105",
+	0xACC8580A: "6106",
+	0x009E9EA3: "7102",
+	0x0E018159: "8303",
+}
+
+// PAL CICs run the same IPL3 as their NTSC counterparts, so they're told apart by region
+var palCICs = map[string]string{
+	"6102": "7101",
+	"6103": "7103",
+	"6105": "7105",
+	"6106": "7106",
+}
+
+// The libdragon IPL3 is rebuilt often, so it's found by the name it carries instead of a checksum
+var libdragonSignature = []byte("libdragon")
+
+// Identify the CIC from the IPL3 bootcode. Returns an empty name with low
+// confidence when the bootcode is unknown.
+func detectCIC(bootcode []byte, videoSystem string) (cic string, confidence string, ipl3crc uint32) {
+	ipl3crc = crc32.ChecksumIEEE(bootcode)
+
+	if cic, ok := ipl3Checksums[ipl3crc]; ok {
+		if pal, ok := palCICs[cic]; ok && videoSystem == PAL {
+			cic = pal
+		}
+		return cic, CICConfidenceHigh, ipl3crc
+	}
+
+	if bytes.Contains(bootcode, libdragonSignature) {
+		return "libdragon", CICConfidenceMedium, ipl3crc
+	}
+
+	return "", CICConfidenceLow, ipl3crc
+}
+
+// The CIC used to calculate CRCs. Unknown CICs are treated as a 6102.
+func (rf *RomFile) cicType() CICType {
+	if rf.foundCIC != nil {
+		return *rf.foundCIC
+	}
+	if cic, ok := CICTypes[rf.CIC]; ok {
+		return cic
+	}
+	return CICTypes["6102"]
+}
+
+// Calculate the CRCs from ROM data in Z64 byte order. When the bootcode is unknown,
+// the known CICs are tried to find the one that gives the CRCs in the header, and with
+// searchSeeds, every seed of every CRC variant too. A match identifies the CIC with
+// medium confidence.
+func (rf *RomFile) calcCRCFrom(data []byte, searchSeeds bool) {
+	if rf.CICConfidence != CICConfidenceLow {
+		rf.setCRC(calcCRC(data, rf.cicType()))
+		return
+	}
+
+	cic, ok := findKnownCIC(data, rf.CRC1, rf.CRC2)
+	if !ok && searchSeeds {
+		cic, ok = findCICBySeed(data, rf.CRC1, rf.CRC2)
+	}
+	if ok {
+		rf.CIC = cic.Name
+		rf.CICConfidence = CICConfidenceMedium
+		rf.foundCIC = &cic
+		rf.setCRC(calcCRC(data, cic))
+		return
+	}
+
+	rf.setCRC(calcCRC(data, rf.cicType()))
+}
+
+// Whether the CIC produces the given CRCs from the data
+func cicMatches(data []byte, cic CICType, crc1 string, crc2 string) bool {
+	c1, c2 := calcCRC(data, cic)
+	return fmt.Sprintf("%08X", c1) == crc1 && fmt.Sprintf("%08X", c2) == crc2
+}
+
+// Try the known CICs for the one that produces the given CRCs
+func findKnownCIC(data []byte, crc1 string, crc2 string) (CICType, bool) {
+	for _, name := range []string{"6102", "6101", "6103", "6105", "6106", "5101", "8303"} {
+		if cic := CICTypes[name]; cicMatches(data, cic, crc1, crc2) {
+			return cic, true
+		}
+	}
+	return CICType{}, false
+}
+
+// Try every seed with every algorithm for the one that produces the given CRCs.
+// Seeds that don't belong to a known CIC are named after the CIC they're closest
+// to, e.g. "6102 (seed 0x12)".
+func findCICBySeed(data []byte, crc1 string, crc2 string) (CICType, bool) {
+	for _, algorithm := range crcAlgorithms {
+		for seed := 0; seed <= 0xFF; seed++ {
+			if cic := seedCIC(algorithm, byte(seed)); cicMatches(data, cic, crc1, crc2) {
+				return cic, true
+			}
+		}
+	}
+	return CICType{}, false
+}
+
+// A CIC that isn't known, identified by its algorithm and seed
+func seedCIC(algorithm crcAlgorithm, seed byte) CICType {
+	base := crcAlgorithmNames[algorithm]
+	return CICType{
+		Name:        fmt.Sprintf("%s (seed 0x%02X)", base, seed),
+		Description: fmt.Sprintf("Unknown CIC with the %s algorithm and seed 0x%02X", base, seed),
+		Seed:        seed,
+		algorithm:   algorithm,
+	}
+}
+
+// The CIC with a name given by findCICBySeed
+func cicFromName(name string) (CICType, bool) {
+	if cic, ok := CICTypes[name]; ok {
+		return cic, true
+	}
+
+	var base string
+	var seed byte
+	if _, err := fmt.Sscanf(name, "%s (seed 0x%X)", &base, &seed); err != nil {
+		return CICType{}, false
+	}
+	for algorithm, algorithmName := range crcAlgorithmNames {
+		if algorithmName == base {
+			return seedCIC(algorithm, seed), true
+		}
+	}
+	return CICType{}, false
+}
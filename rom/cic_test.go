@@ -0,0 +1,43 @@
+package rom
+
+import "testing"
+
+func TestSeedSearchOnlyWhenAsked(t *testing.T) {
+	want := seedCIC(crcAlgorithm6103, 0x12)
+	path := writeUnknownCICRom(t, want)
+
+	rf, err := FromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.CalcCRC(); err != nil {
+		t.Fatal(err)
+	}
+	if rf.CICConfidence != CICConfidenceLow {
+		t.Errorf("CalcCRC found %s without searching the seeds", rf.CIC)
+	}
+
+	rf, err = FromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.CalcCRCSearchingSeeds(); err != nil {
+		t.Fatal(err)
+	}
+	if rf.CIC != want.Name || rf.CICConfidence != CICConfidenceMedium || !rf.CRCValid() {
+		t.Errorf("found %s with %s confidence", rf.CIC, rf.CICConfidence)
+	}
+}
+
+func TestCICFromName(t *testing.T) {
+	for _, cic := range []CICType{CICTypes["6105"], seedCIC(crcAlgorithm6106, 0xA0), seedCIC(crcAlgorithm6102, 0x00)} {
+		if got, ok := cicFromName(cic.Name); !ok || got != cic {
+			t.Errorf("%s: got %+v", cic.Name, got)
+		}
+	}
+	for _, name := range []string{"", "9999", "7777 (seed 0x12)"} {
+		if _, ok := cicFromName(name); ok {
+			t.Errorf("%s: found a CIC", name)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package rom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mroach/rom64/patch"
+)
+
+// Parts of a ROM that differences are reported in
+const (
+	AreaHeader   = "header"
+	AreaBootcode = "ipl3"
+	AreaCRC      = "crc"
+	AreaData     = "data"
+)
+
+type area struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// The IPL3 bootcode follows the header, then the CRCs cover the first 1MB of the game.
+// The data area runs to the end of the larger ROM.
+var romAreas = []area{
+	{AreaHeader, 0, ROM_HEADER_SIZE},
+	{AreaBootcode, ROM_HEADER_SIZE, CRC_CHECKSUM_START},
+	{AreaCRC, CRC_CHECKSUM_START, CRC_CHECKSUM_END},
+}
+
+type HeaderDifference struct {
+	Field string `json:"field" xml:"field"`
+	A     string `json:"a" xml:"a"`
+	B     string `json:"b" xml:"b"`
+}
+
+// A range of bytes that differs, all within one area
+type DiffRange struct {
+	Offset int    `json:"offset" xml:"offset"`
+	Size   int    `json:"size" xml:"size"`
+	Area   string `json:"area" xml:"area"`
+	// Size as a percentage of the larger ROM
+	Percent float64 `json:"percent" xml:"percent"`
+}
+
+func (r DiffRange) End() int {
+	return r.Offset + r.Size
+}
+
+// How much of an area differs
+type AreaDifference struct {
+	Area      string  `json:"area" xml:"area"`
+	Size      int     `json:"size" xml:"size"`
+	Different int     `json:"different" xml:"different"`
+	Percent   float64 `json:"percent" xml:"percent"`
+}
+
+type Comparison struct {
+	A       RomFile            `json:"a" xml:"a"`
+	B       RomFile            `json:"b" xml:"b"`
+	Headers []HeaderDifference `json:"headers" xml:"headers>difference"`
+	Ranges  []DiffRange        `json:"ranges" xml:"ranges>range"`
+	Areas   []AreaDifference   `json:"areas" xml:"areas>area"`
+	// Bytes that differ, including the bytes one ROM has past the end of the other
+	Different int     `json:"different" xml:"different"`
+	Percent   float64 `json:"percent" xml:"percent"`
+}
+
+func (c *Comparison) Identical() bool {
+	return c.Different == 0
+}
+
+// Compare two ROMs byte by byte, in z64 byte order. Ranges less than gap bytes
+// apart are reported as one range; a gap of 0 reports every difference.
+func Compare(pathA string, pathB string, gap int) (Comparison, error) {
+	var c Comparison
+
+	infoA, err := FromPath(pathA)
+	if err != nil {
+		return c, err
+	}
+	infoB, err := FromPath(pathB)
+	if err != nil {
+		return c, err
+	}
+	c.A, c.B = infoA, infoB
+
+	dataA, err := ReadRomData(pathA, FormatZ64)
+	if err != nil {
+		return c, err
+	}
+	dataB, err := ReadRomData(pathB, FormatZ64)
+	if err != nil {
+		return c, err
+	}
+
+	size := len(dataA)
+	if len(dataB) > size {
+		size = len(dataB)
+	}
+
+	// Diffing the longer against the shorter counts the extra bytes as different
+	longer, shorter := dataA, dataB
+	if len(dataB) > len(dataA) {
+		longer, shorter = dataB, dataA
+	}
+	differences := patch.DiffRegions(shorter, longer)
+
+	areas := romAreas
+	if infoA.IsDisk() || infoB.IsDisk() {
+		areas = nil
+	}
+
+	counts := make(map[string]int)
+	for _, region := range differences {
+		for _, r := range splitByArea(region, areas) {
+			counts[r.Area] += r.Size
+			c.Different += r.Size
+		}
+	}
+
+	c.Ranges = make([]DiffRange, 0)
+	for _, region := range patch.MergeRegions(differences, gap) {
+		for _, r := range splitByArea(region, areas) {
+			r.Percent = percent(r.Size, size)
+			c.Ranges = append(c.Ranges, r)
+		}
+	}
+
+	c.Areas = make([]AreaDifference, 0, len(areas)+1)
+	for _, a := range append(areas, area{AreaData, areasEnd(areas), size}) {
+		areaSize := a.End - a.Start
+		if a.End > size {
+			areaSize = size - a.Start
+		}
+		if areaSize <= 0 {
+			continue
+		}
+		c.Areas = append(c.Areas, AreaDifference{a.Name, areaSize, counts[a.Name], percent(counts[a.Name], areaSize)})
+	}
+
+	c.Percent = percent(c.Different, size)
+	c.Headers = compareHeaders(infoA, infoB, dataA, dataB)
+	return c, nil
+}
+
+// Split a region where it crosses from one area to the next
+func splitByArea(region patch.Region, areas []area) []DiffRange {
+	ranges := make([]DiffRange, 0, 1)
+	offset := region.Offset
+	for _, a := range areas {
+		if offset >= a.End || offset >= region.End() {
+			continue
+		}
+		end := region.End()
+		if end > a.End {
+			end = a.End
+		}
+		ranges = append(ranges, DiffRange{Offset: offset, Size: end - offset, Area: a.Name})
+		offset = end
+	}
+	if offset < region.End() {
+		ranges = append(ranges, DiffRange{Offset: offset, Size: region.End() - offset, Area: AreaData})
+	}
+	return ranges
+}
+
+func areasEnd(areas []area) int {
+	if len(areas) == 0 {
+		return 0
+	}
+	return areas[len(areas)-1].End
+}
+
+func percent(part int, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+// Compare the parsed header fields. Disks are compared by what was read from their system area.
+func compareHeaders(a RomFile, b RomFile, dataA []byte, dataB []byte) []HeaderDifference {
+	fields := []HeaderDifference{
+		{"file_format", a.File.Format.Code, b.File.Format.Code},
+		{"file_size", fmt.Sprint(len(dataA)), fmt.Sprint(len(dataB))},
+		{"image_name", a.ImageName, b.ImageName},
+		{"media_format", a.MediaFormat.Code, b.MediaFormat.Code},
+		{"cartridge_id", a.CartridgeId, b.CartridgeId},
+		{"region", a.Region.Id, b.Region.Id},
+		{"version", fmt.Sprint(a.Version), fmt.Sprint(b.Version)},
+		{"crc1", a.CRC1, b.CRC1},
+		{"crc2", a.CRC2, b.CRC2},
+		{"cic", a.CIC, b.CIC},
+		{"ipl3_crc32", a.IPL3CRC32, b.IPL3CRC32},
+		{"homebrew", homebrewSummary(a), homebrewSummary(b)},
+	}
+
+	var headerA, headerB Header
+	if !a.IsDisk() && !b.IsDisk() && headerA.UnmarshalBinary(dataA) == nil && headerB.UnmarshalBinary(dataB) == nil {
+		fields = append(fields,
+			HeaderDifference{"clock_rate", fmt.Sprintf("%08X", headerA.ClockRate), fmt.Sprintf("%08X", headerB.ClockRate)},
+			HeaderDifference{"program_counter", fmt.Sprintf("%08X", headerA.ProgramCounter), fmt.Sprintf("%08X", headerB.ProgramCounter)},
+			HeaderDifference{"release_address", fmt.Sprintf("%08X", headerA.ReleaseAddress), fmt.Sprintf("%08X", headerB.ReleaseAddress)},
+			HeaderDifference{"unknown_18", fmt.Sprintf("%X", headerA.Unknown1), fmt.Sprintf("%X", headerB.Unknown1)},
+			HeaderDifference{"unknown_34", fmt.Sprintf("%X", headerA.Unknown2), fmt.Sprintf("%X", headerB.Unknown2)},
+			HeaderDifference{"unknown_38", fmt.Sprintf("%X", headerA.Unknown3), fmt.Sprintf("%X", headerB.Unknown3)},
+		)
+	}
+
+	differences := make([]HeaderDifference, 0)
+	for _, field := range fields {
+		if field.A != field.B {
+			differences = append(differences, field)
+		}
+	}
+	return differences
+}
+
+// The homebrew header settings in one field, like "eeprom16k rtc region-free"
+func homebrewSummary(r RomFile) string {
+	if r.Homebrew == nil {
+		return ""
+	}
+	settings := []string{r.Homebrew.SaveType.Code}
+	if r.Homebrew.RTC {
+		settings = append(settings, "rtc")
+	}
+	if r.Homebrew.RegionFree {
+		settings = append(settings, "region-free")
+	}
+	return strings.Join(settings, " ")
+}
@@ -1,14 +1,22 @@
 package rom
 
 import (
-	"errors"
+	"fmt"
 	"io"
 	"os"
 )
 
 // Converts a ROM to native Z64 format
 func ConvertRomFormat(inpath string, outpath string) error {
-	const bufferSize = 2048
+	return ConvertRomFormatTo(inpath, outpath, FormatZ64)
+}
+
+// Converts a ROM from whatever format it's in to the target format.
+// The target format must be one of FormatZ64, FormatV64, or FormatN64.
+func ConvertRomFormatTo(inpath string, outpath string, targetFormat string) error {
+	if _, ok := FileFormats[targetFormat]; !ok {
+		return fmt.Errorf("Unknown target format '%s'", targetFormat)
+	}
 
 	info, err := FromPath(inpath)
 	if err != nil {
@@ -17,66 +25,58 @@ func ConvertRomFormat(inpath string, outpath string) error {
 
 	fileFormat := info.File.Format.Code
 
-	if fileFormat == FormatZ64 {
-		return errors.New("File is already in the native Z64 format")
+	if info.IsDisk() {
+		return fmt.Errorf("64DD disk images don't have a byte order to convert")
+	}
+	if fileFormat == targetFormat {
+		return fmt.Errorf("File is already in the %s (%s) format", targetFormat, FileFormats[targetFormat])
 	}
 
-	source, err := os.Open(inpath)
+	source, err := openRom(inpath)
 	if err != nil {
 		return err
 	}
 	defer source.Close()
 
+	reader, err := NewByteOrderReader(source, fileFormat, targetFormat)
+	if err != nil {
+		return err
+	}
+
 	dest, err := os.Create(outpath)
 	if err != nil {
 		return err
 	}
 	defer dest.Close()
 
-	for {
-		buf := make([]byte, bufferSize)
-		n, err := source.Read(buf)
-		if err != nil && err != io.EOF {
-			return err
-		}
-		if n == 0 {
-			break
-		}
-
-		buf = maybeReverseBytes(buf, fileFormat)
-		if _, err := dest.Write(buf[:n]); err != nil {
-			return err
-		}
+	if _, err := io.Copy(dest, reader); err != nil {
+		return err
 	}
 
-	return nil
+	return dest.Close()
 }
 
-func maybeReverseBytes(bytes []byte, romFormat string) []byte {
-	if romFormat == FormatV64 {
-		return reverseBytes(bytes, 2)
+// Read a whole ROM into memory, converted to the target format. 64DD disk images
+// have no byte order, so they're read as they are.
+func ReadRomData(path string, targetFormat string) ([]byte, error) {
+	info, err := FromPath(path)
+	if err != nil {
+		return nil, err
 	}
 
-	if romFormat == FormatN64 {
-		return reverseBytes(bytes, 4)
+	source, err := openRom(path)
+	if err != nil {
+		return nil, err
 	}
+	defer source.Close()
 
-	return bytes
-}
-
-func reverseBytes(bytes []byte, size int) (reversed []byte) {
-	for _, chunk := range chunk(bytes, size) {
-		for i := len(chunk) - 1; i >= 0; i = i - 1 {
-			reversed = append(reversed, chunk[i])
-		}
+	if info.IsDisk() {
+		return io.ReadAll(source)
 	}
 
-	return reversed
-}
-
-func chunk(bytes []byte, chunkSize int) (chunks [][]byte) {
-	for chunkSize < len(bytes) {
-		bytes, chunks = bytes[chunkSize:], append(chunks, bytes[0:chunkSize:chunkSize])
+	reader, err := NewByteOrderReader(source, info.File.Format.Code, targetFormat)
+	if err != nil {
+		return nil, err
 	}
-	return append(chunks, bytes)
+	return io.ReadAll(reader)
 }
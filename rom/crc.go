@@ -1,8 +1,11 @@
 package rom
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 )
 
 const (
@@ -11,40 +14,67 @@ const (
 	CRC_CHECKSUM_END    = CRC_CHECKSUM_START + CRC_CHECKSUM_LENGTH
 )
 
-func crcSeedForCIC(cic string) uint32 {
-	switch cic {
-	case "6101", "6102":
-		return 0xF8CA4DDC
-	case "6103":
-		return 0xA3886759
-	case "6105":
-		return 0xDF26F436
-	case "6106":
-		return 0x1FEA617A
-	default:
-		return 1
-	}
-}
+// Absolute offset of CRC1 in the ROM header. CRC2 immediately follows it.
+const CRC_HEADER_OFFSET = 0x10
 
 // Calculate CRC1 (aka "CRC HI") and CRC2 (aka "CRC LO") for the given RomFile
 //
 // This code is a direct port from the C implementation at http://n64dev.org/n64crc.html
 // Since I don't understand the "why" behind this, I can't document it further.
 func (rf *RomFile) CalcCRC() error {
-	file, err := os.Open(rf.File.Path)
+	data, err := rf.readCRCData()
 	if err != nil {
 		return err
 	}
+	rf.calcCRCFrom(data, false)
+	return nil
+}
+
+// Same as CalcCRC, but when the bootcode is unknown, every seed of every CRC variant
+// is tried to find the CIC. That's hundreds of CRC calculations, so it's only worth
+// doing before the CRCs are written.
+func (rf *RomFile) CalcCRCSearchingSeeds() error {
+	data, err := rf.readCRCData()
+	if err != nil {
+		return err
+	}
+	rf.calcCRCFrom(data, true)
+	return nil
+}
+
+// Read the data the CRCs cover, in Z64 byte order
+func (rf *RomFile) readCRCData() ([]byte, error) {
+	if rf.IsDisk() {
+		return nil, fmt.Errorf("64DD disk images don't have a CRC: %s", rf.File.Path)
+	}
+
+	file, err := openRom(rf.File.Path)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
+	reader, err := NewByteOrderReader(file, rf.File.Format.Code, FormatZ64)
+	if err != nil {
+		return nil, err
+	}
+
 	bytes := make([]byte, CRC_CHECKSUM_END)
-	if _, err := file.Read(bytes); err != nil {
-		return err
+	if _, err := io.ReadFull(reader, bytes); err != nil {
+		return nil, err
 	}
-	bytes = maybeReverseBytes(bytes, rf.File.Format.Code)
+	return bytes, nil
+}
 
-	cic := rf.CIC
-	seed := crcSeedForCIC(cic)
+func (rf *RomFile) setCRC(crc1, crc2 uint32) {
+	rf.File.CRC1 = fmt.Sprintf("%08X", crc1)
+	rf.File.CRC2 = fmt.Sprintf("%08X", crc2)
+}
+
+// Calculate CRC1 and CRC2 over big-endian ROM data. The data must be at least
+// CRC_CHECKSUM_END bytes long.
+func calcCRC(bytes []byte, cic CICType) (crc1, crc2 uint32) {
+	seed := cic.crcSeed()
 	t1, t2, t3, t4, t5, t6 := seed, seed, seed, seed, seed, seed
 
 	for i := CRC_CHECKSUM_START; i < CRC_CHECKSUM_END; i += 4 {
@@ -66,7 +96,7 @@ func (rf *RomFile) CalcCRC() error {
 			t2 ^= t6 ^ d
 		}
 
-		if cic == "6105" {
+		if cic.algorithm == crcAlgorithm6105 {
 			extra := ROM_HEADER_SIZE + 0x0710 + (i & 0xFF)
 			b := uint32be(bytes[extra : extra+4])
 			t1 += b ^ d
@@ -76,13 +106,11 @@ func (rf *RomFile) CalcCRC() error {
 
 	}
 
-	var crc1, crc2 uint32
-
-	switch cic {
-	case "6103":
+	switch cic.algorithm {
+	case crcAlgorithm6103:
 		crc1 = (t6 ^ t4) + t3
 		crc2 = (t5 ^ t2) + t1
-	case "6106":
+	case crcAlgorithm6106:
 		crc1 = (t6 * t4) + t3
 		crc2 = (t5 * t2) + t1
 	default:
@@ -90,11 +118,72 @@ func (rf *RomFile) CalcCRC() error {
 		crc2 = t5 ^ t2 ^ t1
 	}
 
-	rf.File.CRC1 = fmt.Sprintf("%08X", crc1)
-	rf.File.CRC2 = fmt.Sprintf("%08X", crc2)
+	return crc1, crc2
+}
+
+// Whether the CRC1 and CRC2 in the header match the calculated values.
+// Returns false when the CRCs have not been calculated yet.
+func (rf *RomFile) CRCValid() bool {
+	if rf.File.CRC1 == "" || rf.File.CRC2 == "" {
+		return false
+	}
+	return rf.CRC1 == rf.File.CRC1 && rf.CRC2 == rf.File.CRC2
+}
+
+// Write the calculated CRC1 and CRC2 back into the ROM header on disk.
+//
+// The values are written in the file's own byte order, so v64 and n64 files
+// are patched in place without being converted first. CalcCRC must be called
+// before this.
+func (rf *RomFile) WriteCRC() error {
+	if rf.File.CRC1 == "" || rf.File.CRC2 == "" {
+		return fmt.Errorf("CRCs have not been calculated for %s", rf.File.Path)
+	}
+	if rf.File.Archive != "" {
+		return fmt.Errorf("Can't write to a ROM inside an archive: %s", rf.File.Path)
+	}
+
+	crc1, err := strconv.ParseUint(rf.File.CRC1, 16, 32)
+	if err != nil {
+		return err
+	}
+	crc2, err := strconv.ParseUint(rf.File.CRC2, 16, 32)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteHeaderCRC(rf.File.Path, rf.File.Format.Code, uint32(crc1), uint32(crc2)); err != nil {
+		return err
+	}
+
+	rf.CRC1 = rf.File.CRC1
+	rf.CRC2 = rf.File.CRC2
 	return nil
 }
 
+// Write CRC1 and CRC2 into the header of the ROM at the given path.
+// romFormat is the on-disk format of the file (z64, v64, or n64).
+func WriteHeaderCRC(path string, romFormat string, crc1, crc2 uint32) error {
+	bytes := make([]byte, 8)
+	binary.BigEndian.PutUint32(bytes[0:4], crc1)
+	binary.BigEndian.PutUint32(bytes[4:8], crc2)
+	if err := SwapByteOrder(bytes, FormatZ64, romFormat); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteAt(bytes, CRC_HEADER_OFFSET); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
 func rol(i uint32, b int) uint32 {
 	return (i << b) | (i >> (32 - b))
 }
@@ -0,0 +1,164 @@
+package rom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 64DD disk image layouts
+const (
+	// Full retail dump: every block of the disk in LBA order, including the system area
+	FormatNDD = "ndd"
+	// Development layout: system data and disk ID in a 0x200-byte header, then the data
+	FormatD64 = "d64"
+)
+
+var DiskFormats = map[string]string{
+	FormatNDD: "64DD disk (NDD)",
+	FormatD64: "64DD disk (D64)",
+}
+
+// Blocks in the system area are in zone 0: 85 sectors of 232 bytes
+const (
+	DISK_SECTOR_SIZE = 0xE8
+	DISK_BLOCK_SIZE  = DISK_SECTOR_SIZE * 85
+
+	// Where the D64 header keeps copies of the system data and disk ID sectors
+	D64_SYSTEM_DATA_OFFSET = 0x000
+	D64_DISK_ID_OFFSET     = 0x100
+	D64_HEADER_SIZE        = 0x200
+)
+
+// System area blocks holding the system data. Retail and development disks keep
+// theirs in different blocks; each is stored more than once in case one is damaged.
+var (
+	retailSystemLBAs      = []int{0, 1, 8, 9}
+	developmentSystemLBAs = []int{2, 3, 10, 11}
+	diskIdLBAs            = []int{14, 15}
+)
+
+var DiskRegions = map[string]CodeDescription{
+	string(diskIdJapan):       {"JPN", "Japan"},
+	string(diskIdUSA):         {"USA", "USA"},
+	string(diskIdDevelopment): {"DEV", "Development"},
+}
+
+// Information from the 64DD system area. The game code, version, and region
+// letter are reported in RomFile like they are for cartridges.
+type DiskInfo struct {
+	// Region the drive must be from to read the disk
+	Region CodeDescription `json:"region" xml:"region"`
+	// 0-6. Decides how much of the disk is read-only and how much is writable.
+	Type       int    `json:"type" xml:"type"`
+	DiskNumber int    `json:"disk_number" xml:"disk_number"`
+	Company    string `json:"company" xml:"company"`
+}
+
+// Whether the file is a 64DD disk image rather than a cartridge ROM
+func (r *RomFile) IsDisk() bool {
+	return r.Disk != nil
+}
+
+func isDiskFormat(format string) bool {
+	_, ok := DiskFormats[format]
+	return ok
+}
+
+// Read a 64DD disk image. The layout is decided by the size: full-size images are NDD,
+// anything else is D64. Only the system area at the start of the image is read.
+func FromDiskReader(r io.Reader, size int64) (RomFile, error) {
+	var info RomFile
+
+	format := FormatD64
+	headerSize := int64(D64_HEADER_SIZE)
+	if size == NDD_DISK_SIZE {
+		format = FormatNDD
+		headerSize = int64((diskIdLBAs[len(diskIdLBAs)-1] + 1) * DISK_BLOCK_SIZE)
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return info, err
+	}
+
+	var systemData, diskId []byte
+	if format == FormatNDD {
+		systemData = findSystemData(header)
+		diskId = diskBlock(header, diskIdLBAs[0])[:DISK_SECTOR_SIZE]
+	} else {
+		systemData = header[D64_SYSTEM_DATA_OFFSET : D64_SYSTEM_DATA_OFFSET+DISK_SECTOR_SIZE]
+		diskId = header[D64_DISK_ID_OFFSET : D64_DISK_ID_OFFSET+DISK_SECTOR_SIZE]
+	}
+
+	if systemData == nil {
+		return info, fmt.Errorf("No 64DD system data found. Invalid disk image?")
+	}
+	region, ok := DiskRegions[string(systemData[0:4])]
+	if !ok {
+		return info, fmt.Errorf("Unknown 64DD disk region %X. Invalid disk image?", systemData[0:4])
+	}
+
+	// Game code, like a cartridge serial: media type, two-character ID, region
+	gameCode := bytesToString(diskId[0:4])
+	mediaFormatCode, cartridgeId, regionCode := "", "", ""
+	if len(gameCode) == 4 {
+		mediaFormatCode, cartridgeId, regionCode = gameCode[0:1], gameCode[1:3], gameCode[3:4]
+	}
+
+	info = RomFile{
+		CartridgeId: cartridgeId,
+		Version:     diskId[4],
+		Region:      Regions[regionCode],
+		MediaFormat: CodeDescription{
+			Code:        mediaFormatCode,
+			Description: MediaFormats[mediaFormatCode],
+		},
+		File: FileInfo{
+			Format: CodeDescription{
+				Code:        format,
+				Description: DiskFormats[format],
+			},
+		},
+		Disk: &DiskInfo{
+			Region:     region,
+			Type:       int(systemData[5] & 0x0F),
+			DiskNumber: int(diskId[5]),
+			Company:    strings.TrimSpace(bytesToString(diskId[0x18:0x1A])),
+		},
+	}
+
+	return info, nil
+}
+
+// Find a good copy of the system data in an NDD image. Retail copies are
+// preferred since a blank block looks like development system data.
+func findSystemData(image []byte) []byte {
+	for _, lba := range retailSystemLBAs {
+		data := diskBlock(image, lba)[:DISK_SECTOR_SIZE]
+		if bytes.HasPrefix(data, diskIdJapan) || bytes.HasPrefix(data, diskIdUSA) {
+			return data
+		}
+	}
+	for _, lba := range developmentSystemLBAs {
+		data := diskBlock(image, lba)[:DISK_SECTOR_SIZE]
+		if bytes.HasPrefix(data, diskIdDevelopment) && !isBlank(data) {
+			return data
+		}
+	}
+	return nil
+}
+
+func diskBlock(image []byte, lba int) []byte {
+	return image[lba*DISK_BLOCK_SIZE : (lba+1)*DISK_BLOCK_SIZE]
+}
+
+func isBlank(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package rom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mroach/rom64/dat"
+)
+
+// What happens to a file in a group of duplicates
+const (
+	// The copy to keep
+	DupeKeep = "keep"
+	// Another copy. Nothing is done to it.
+	DupeExtra = "duplicate"
+	// Delete the copy
+	DupeDelete = "delete"
+	// Replace the copy with a hard link to the kept file
+	DupeLink = "link"
+	// Left alone because it can't be deleted or linked
+	DupeSkip = "skip"
+)
+
+type DuplicateFile struct {
+	Action string `json:"action" xml:"action,attr"`
+	// Why the file is skipped
+	Reason string   `json:"reason,omitempty" xml:"reason,omitempty"`
+	Rom    *RomFile `json:"rom" xml:"rom"`
+}
+
+// Files with the same normalized contents, no matter the byte order
+type DuplicateGroup struct {
+	SHA1 string `json:"sha1" xml:"sha1,attr"`
+	// The datfile entry for the contents, if there is one
+	Dat *dat.Rom `json:"dat,omitempty" xml:"dat,omitempty"`
+	// The first file is the one to keep
+	Files []DuplicateFile `json:"files" xml:"file"`
+}
+
+// Group ROMs with the same normalized SHA-1. Only groups with more than one file
+// are returned. The datfile may be nil.
+//
+// The copy to keep is picked in this order: files that aren't symlinks, z64 files,
+// files named like the datfile entry, plain files over archive entries, then the shortest path.
+func FindDuplicates(roms []RomFile, df *dat.DatFile) []DuplicateGroup {
+	bySHA1 := make(map[string][]*RomFile)
+	order := make([]string, 0)
+
+	for i := range roms {
+		romfile := &roms[i]
+		sha1 := romfile.NormalizedSHA1()
+		if sha1 == "" {
+			continue
+		}
+		if _, ok := bySHA1[sha1]; !ok {
+			order = append(order, sha1)
+		}
+		bySHA1[sha1] = append(bySHA1[sha1], romfile)
+	}
+
+	groups := make([]DuplicateGroup, 0)
+	for _, sha1 := range order {
+		files := bySHA1[sha1]
+		if len(files) < 2 {
+			continue
+		}
+
+		group := DuplicateGroup{SHA1: sha1}
+		if df != nil {
+			if matches, err := files[0].findDatMatches(*df); err == nil && len(matches) > 0 {
+				group.Dat = &matches[0]
+			}
+		}
+
+		sort.SliceStable(files, func(i, j int) bool {
+			return group.prefer(files[i], files[j])
+		})
+
+		for i, romfile := range files {
+			action := DupeExtra
+			if i == 0 {
+				action = DupeKeep
+			}
+			group.Files = append(group.Files, DuplicateFile{Action: action, Rom: romfile})
+		}
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Keep().File.Path < groups[j].Keep().File.Path
+	})
+
+	return groups
+}
+
+// The file to keep
+func (g *DuplicateGroup) Keep() *RomFile {
+	return g.Files[0].Rom
+}
+
+// Whether a is a better copy to keep than b
+func (g *DuplicateGroup) prefer(a, b *RomFile) bool {
+	// Keeping a symlink and removing what it points to would lose the ROM
+	if aLink, bLink := isSymlink(a.File.Path), isSymlink(b.File.Path); aLink != bLink {
+		return bLink
+	}
+	if aZ64, bZ64 := a.File.Format.Code == FormatZ64, b.File.Format.Code == FormatZ64; aZ64 != bZ64 {
+		return aZ64
+	}
+	if aNamed, bNamed := g.hasDatName(a), g.hasDatName(b); aNamed != bNamed {
+		return aNamed
+	}
+	if aPlain, bPlain := a.File.Archive == "", b.File.Archive == ""; aPlain != bPlain {
+		return aPlain
+	}
+	if len(a.File.Path) != len(b.File.Path) {
+		return len(a.File.Path) < len(b.File.Path)
+	}
+	return a.File.Path < b.File.Path
+}
+
+// Whether the file is named after the datfile entry. The extension doesn't matter
+// since files in other byte orders keep theirs when renamed.
+func (g *DuplicateGroup) hasDatName(romfile *RomFile) bool {
+	if g.Dat == nil {
+		return false
+	}
+	stem := func(name string) string {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return stem(romfile.File.Name) == stem(g.Dat.Name)
+}
+
+// Decide what to do with the copies that aren't kept: DupeDelete, DupeLink, or DupeExtra
+// to leave them alone. Copies that can't be changed are marked DupeSkip with a reason.
+//
+// Hard links need the file SHA-1 to be calculated, since only byte-for-byte
+// identical files can be linked. Files in a different byte order are skipped.
+func (g *DuplicateGroup) Plan(action string) {
+	keep := g.Keep()
+
+	for i := 1; i < len(g.Files); i++ {
+		file := &g.Files[i]
+		file.Action, file.Reason = action, ""
+
+		switch {
+		case file.Rom.File.Path == keep.File.Path || SameFile(file.Rom.File.Path, keep.File.Path):
+			// Changing it would change the kept file too
+			file.Action, file.Reason = DupeSkip, "same file as the kept copy"
+		case action == DupeExtra:
+		case file.Rom.File.Archive != "":
+			file.Action, file.Reason = DupeSkip, "inside an archive"
+		case action == DupeLink && keep.File.Archive != "":
+			file.Action, file.Reason = DupeSkip, "the kept file is inside an archive"
+		case action == DupeLink && (file.Rom.File.SHA1 == "" || file.Rom.File.SHA1 != keep.File.SHA1):
+			file.Action, file.Reason = DupeSkip, fmt.Sprintf("%s file can't be linked to %s file", file.Rom.File.Format.Code, keep.File.Format.Code)
+		}
+	}
+}
+
+// Carry out the planned actions. Returns an error for each file that couldn't be changed.
+func (g *DuplicateGroup) Apply() (errs []error) {
+	keep := g.Keep()
+
+	for _, file := range g.Files[1:] {
+		var err error
+		switch file.Action {
+		case DupeDelete:
+			err = os.Remove(file.Rom.File.Path)
+		case DupeLink:
+			err = replaceWithLink(keep.File.Path, file.Rom.File.Path)
+		default:
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Rom.File.Path, err))
+		}
+	}
+
+	return errs
+}
+
+func isSymlink(fpath string) bool {
+	info, err := os.Lstat(fpath)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// Swap a file for a hard link to target. The link is made under a temporary
+// name first so the file isn't lost if linking fails.
+func replaceWithLink(target string, fpath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fpath), ".rom64-link-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+
+	if err := os.Link(target, tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), fpath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package rom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func dupeRom(path string) RomFile {
+	return RomFile{SHA1: "AAAA", File: FileInfo{Path: path, Name: filepath.Base(path), Format: CodeDescription{Code: FormatZ64}}}
+}
+
+func TestFindDuplicatesNeverKeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "some", "longer", "dir", "Game.z64")
+	link := filepath.Join(dir, "g.z64")
+	if err := os.MkdirAll(filepath.Dir(real), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(real, []byte("rom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("symlinks aren't supported:", err)
+	}
+
+	groups := FindDuplicates([]RomFile{dupeRom(link), dupeRom(real)}, nil)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups", len(groups))
+	}
+	group := groups[0]
+	if group.Keep().File.Path != real {
+		t.Errorf("kept %s instead of the file the symlink points to", group.Keep().File.Path)
+	}
+
+	group.Plan(DupeDelete)
+	if file := group.Files[1]; file.Action != DupeSkip {
+		t.Errorf("the symlink to the kept file would be %s", file.Action)
+	}
+}
+
+func TestPlanSkipsTheKeptPath(t *testing.T) {
+	group := DuplicateGroup{Files: []DuplicateFile{
+		{Action: DupeKeep, Rom: &RomFile{File: FileInfo{Path: "Game.z64"}}},
+		{Action: DupeExtra, Rom: &RomFile{File: FileInfo{Path: "Game.z64"}}},
+	}}
+
+	for _, action := range []string{DupeDelete, DupeLink, DupeExtra} {
+		group.Plan(action)
+		if file := group.Files[1]; file.Action != DupeSkip {
+			t.Errorf("%s: the kept path would be %s", action, file.Action)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package rom
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Longest image name the header has room for
+const IMAGE_NAME_LENGTH = 20
+
+// Changes to make to a ROM header. Nil fields are left alone.
+type HeaderChanges struct {
+	ImageName   *string
+	CartridgeId *string
+	RegionCode  *string
+	MediaFormat *string
+	Version     *uint8
+	ClockRate   *uint32
+	// Adds the ED64 homebrew header, which takes over the cartridge ID and version
+	Homebrew *HomebrewHeader
+	// Removes the homebrew header. A new cartridge ID has to be given with it.
+	RemoveHomebrew bool
+}
+
+// Check the changes can be written to the current header
+func (c *HeaderChanges) Validate(current Header) error {
+	if c.ImageName != nil {
+		if len(*c.ImageName) > IMAGE_NAME_LENGTH {
+			return fmt.Errorf("Image name can be at most %d characters: '%s'", IMAGE_NAME_LENGTH, *c.ImageName)
+		}
+		if !isPrintableASCII(*c.ImageName) {
+			return fmt.Errorf("Image name can only have printable ASCII characters: '%s'", *c.ImageName)
+		}
+	}
+	if c.CartridgeId != nil && (len(*c.CartridgeId) != 2 || !isPrintableASCII(*c.CartridgeId)) {
+		return fmt.Errorf("Cartridge ID must be 2 ASCII characters: '%s'", *c.CartridgeId)
+	}
+	if c.RegionCode != nil {
+		if _, ok := Regions[*c.RegionCode]; !ok {
+			return fmt.Errorf("Unknown region code '%s'. Must be one of: %s", *c.RegionCode, strings.Join(regionCodes(), ", "))
+		}
+	}
+	if c.MediaFormat != nil {
+		if _, ok := MediaFormats[*c.MediaFormat]; !ok {
+			return fmt.Errorf("Unknown media format '%s'. Must be one of: %s", *c.MediaFormat, strings.Join(mediaFormatCodes(), ", "))
+		}
+	}
+	if c.CartridgeId != nil && *c.CartridgeId == HomebrewId {
+		return fmt.Errorf("Cartridge ID '%s' marks the homebrew header. Use the homebrew options to add it.", HomebrewId)
+	}
+	if c.Homebrew != nil {
+		if c.CartridgeId != nil || c.Version != nil || c.RemoveHomebrew {
+			return fmt.Errorf("The homebrew header replaces the cartridge ID and version, so they can't be set with it")
+		}
+		if _, err := c.Homebrew.config(); err != nil {
+			return err
+		}
+	}
+
+	hasHomebrew := string(current.CartridgeId[:]) == HomebrewId
+	if c.RemoveHomebrew {
+		if !hasHomebrew {
+			return fmt.Errorf("The ROM doesn't have the homebrew header")
+		}
+		if c.CartridgeId == nil {
+			return fmt.Errorf("Removing the homebrew header needs a new cartridge ID")
+		}
+	} else if hasHomebrew && (c.CartridgeId != nil || c.Version != nil) {
+		// The version byte holds the homebrew config, so changing either would silently change or drop it
+		return fmt.Errorf("The ROM has the homebrew header, which holds the cartridge ID and version. Remove the homebrew header to set them.")
+	}
+	return nil
+}
+
+// Apply the changes to a header
+func (c *HeaderChanges) apply(h *Header) {
+	if c.ClockRate != nil {
+		h.ClockRate = *c.ClockRate
+	}
+	if c.ImageName != nil {
+		h.SetName(*c.ImageName)
+	}
+	if c.MediaFormat != nil {
+		h.MediaFormat = (*c.MediaFormat)[0]
+	}
+	if c.CartridgeId != nil {
+		copy(h.CartridgeId[:], *c.CartridgeId)
+	}
+	if c.RegionCode != nil {
+		h.RegionCode = (*c.RegionCode)[0]
+	}
+	if c.Version != nil {
+		h.Version = *c.Version
+	} else if c.RemoveHomebrew {
+		// Don't leave the homebrew config behind as the version
+		h.Version = 0
+	}
+	if c.Homebrew != nil {
+		config, _ := c.Homebrew.config()
+		copy(h.CartridgeId[:], HomebrewId)
+		h.Version = config
+	}
+}
+
+// Change fields in the header of the ROM on disk, in the file's own byte order.
+// Returns the fields that change. With dryRun, nothing is written.
+// The CRCs don't cover the header, but callers may still want to check them with CalcCRC.
+func (rf *RomFile) EditHeader(changes HeaderChanges, dryRun bool) ([]HeaderDifference, error) {
+	if rf.File.Archive != "" {
+		return nil, fmt.Errorf("Can't write to a ROM inside an archive: %s", rf.File.Path)
+	}
+	if rf.IsDisk() {
+		return nil, fmt.Errorf("64DD disk images don't have a ROM header: %s", rf.File.Path)
+	}
+	file, err := os.OpenFile(rf.File.Path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Read up to the end of the bootcode so the edited header can be parsed like a ROM
+	original := make([]byte, CRC_CHECKSUM_START)
+	if _, err := file.ReadAt(original, 0); err != nil {
+		return nil, err
+	}
+
+	var header Header
+	if err := header.UnmarshalBinary(original); err != nil {
+		return nil, err
+	}
+	if err := changes.Validate(header); err != nil {
+		return nil, err
+	}
+	changes.apply(&header)
+	headerData, err := header.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	edited := append([]byte(nil), original...)
+	copy(edited, headerData)
+
+	before, err := FromIoReader(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	after, err := FromIoReader(bytes.NewReader(edited))
+	if err != nil {
+		return nil, err
+	}
+	differences := compareHeaders(before, after, original, edited)
+
+	if dryRun || len(differences) == 0 {
+		return differences, nil
+	}
+
+	if _, err := file.WriteAt(headerData, 0); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	// Keep what was read from the file that the header doesn't hold.
+	// The file hashes and datfile match no longer apply to the edited file.
+	after.File = rf.File
+	after.File.MD5, after.File.SHA1, after.File.SHA256, after.File.CRC32 = "", "", "", ""
+	*rf = after
+	return differences, nil
+}
+
+// Parse a version as shown in the version column, like 1.2, or as the raw number, like 2
+func ParseVersion(version string) (uint8, error) {
+	minor := strings.TrimPrefix(version, "1.")
+	n, err := strconv.ParseUint(minor, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid version '%s'. Use 1.0, 1.1, ... or the version number.", version)
+	}
+	return uint8(n), nil
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+func regionCodes() []string {
+	codes := make([]string, 0, len(Regions))
+	for code := range Regions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func mediaFormatCodes() []string {
+	codes := make([]string, 0, len(MediaFormats))
+	for code := range MediaFormats {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
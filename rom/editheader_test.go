@@ -0,0 +1,75 @@
+package rom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func homebrewHeader() Header {
+	h := Header{ClockRate: 0x0F, MediaFormat: 'N', RegionCode: 'E', Version: 0x51}
+	copy(h.CartridgeId[:], HomebrewId)
+	h.SetName("HOMEBREW")
+	return h
+}
+
+func TestValidateHomebrewHeader(t *testing.T) {
+	id, ed := "MG", HomebrewId
+	version := uint8(1)
+
+	tests := []struct {
+		name    string
+		changes HeaderChanges
+		ok      bool
+	}{
+		{"cartridge ID", HeaderChanges{CartridgeId: &id}, false},
+		{"version", HeaderChanges{Version: &version}, false},
+		{"homebrew options", HeaderChanges{Homebrew: &HomebrewHeader{SaveType: CodeDescription{Code: SaveNone}}}, true},
+		{"remove", HeaderChanges{CartridgeId: &id, RemoveHomebrew: true}, true},
+		{"remove and set the version", HeaderChanges{CartridgeId: &id, Version: &version, RemoveHomebrew: true}, true},
+		{"remove without a cartridge ID", HeaderChanges{RemoveHomebrew: true}, false},
+		{"remove with the homebrew ID", HeaderChanges{CartridgeId: &ed, RemoveHomebrew: true}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.changes.Validate(homebrewHeader()); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+
+	plain := homebrewHeader()
+	copy(plain.CartridgeId[:], "SM")
+	if err := (&HeaderChanges{CartridgeId: &ed}).Validate(plain); err == nil {
+		t.Error("setting the homebrew cartridge ID directly: no error")
+	}
+	if err := (&HeaderChanges{CartridgeId: &id, RemoveHomebrew: true}).Validate(plain); err == nil {
+		t.Error("removing a missing homebrew header: no error")
+	}
+}
+
+func TestEditHeaderRemovesHomebrew(t *testing.T) {
+	data, err := homebrewHeader().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, make([]byte, CRC_CHECKSUM_START-len(data))...)
+	path := filepath.Join(t.TempDir(), "homebrew.z64")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := FromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rf.Homebrew == nil {
+		t.Fatal("the fixture doesn't have the homebrew header")
+	}
+
+	id := "MG"
+	if _, err := rf.EditHeader(HeaderChanges{CartridgeId: &id, RemoveHomebrew: true}, false); err != nil {
+		t.Fatal(err)
+	}
+	if rf.Homebrew != nil || rf.CartridgeId != "MG" || rf.Version != 0 {
+		t.Errorf("got cartridge ID %s, version %d, homebrew %v", rf.CartridgeId, rf.Version, rf.Homebrew)
+	}
+}
@@ -3,47 +3,161 @@ package rom
 import (
 	"os"
 	"path/filepath"
-	"strings"
 )
 
-var Extensions = []string{"bin", "rom", "d64", "n64", "u64", "v64", "z64"}
+var Extensions = []string{"bin", "rom", "d64", "ndd", "n64", "u64", "v64", "z64"}
+
+// Controls how directories are searched for ROM files
+type FindOptions struct {
+	// Descend into subdirectories
+	Recursive bool
+	// Maximum directory depth when recursive. Files directly in the given
+	// directory are depth 1. Zero means no limit.
+	MaxDepth int
+	// Glob patterns a file must match to be included. When empty, files are
+	// included based on their extension. Patterns are matched against the file
+	// name and against the path relative to the search root.
+	Include []string
+	// Glob patterns for files and directories to skip. Matched the same way as Include.
+	Exclude []string
+	// Descend into symlinked directories. Symlinked files are always included.
+	FollowSymlinks bool
+	// Detect ROMs by reading the start of each file instead of checking extensions
+	Sniff bool
+}
 
 // Given a directory or file, filter down to paths that are probably
 // ROM files based on their extensions.
 func FindProbableRomsInPath(path string) ([]string, error) {
-	var unfilteredPaths []string
+	return FindRomsInPath(path, FindOptions{})
+}
+
+// Given a directory or file, find paths that are probably ROM files.
+// A file given directly is only subject to the include and exclude patterns.
+func FindRomsInPath(path string, opts FindOptions) ([]string, error) {
+	probableRomFiles := make([]string, 0)
 
 	fi, err := os.Stat(path)
 	if err != nil {
-		return unfilteredPaths, err
+		return probableRomFiles, err
 	}
 
-	if fi.IsDir() {
-		searchPattern := filepath.Join(path, "*")
-		unfilteredPaths, err = filepath.Glob(searchPattern)
+	found := func(fpath string) error {
+		if !IsArchive(fpath) {
+			probableRomFiles = append(probableRomFiles, fpath)
+			return nil
+		}
+
+		// Archives are expanded into the ROMs inside them. An unreadable archive is
+		// kept as-is so the error surfaces when it's opened.
+		entries, err := ListArchiveRoms(fpath)
 		if err != nil {
-			return unfilteredPaths, err
+			probableRomFiles = append(probableRomFiles, fpath)
+			return nil
 		}
-	} else {
-		unfilteredPaths = []string{path}
+		for _, entry := range entries {
+			probableRomFiles = append(probableRomFiles, ArchiveEntryPath(fpath, entry))
+		}
+		return nil
 	}
 
-	probableRomFiles := make([]string, 0)
-	for _, fpath := range unfilteredPaths {
-		if HasRomExtension(fpath) {
-			probableRomFiles = append(probableRomFiles, fpath)
+	if !fi.IsDir() {
+		if opts.wantFile(path, filepath.Base(path)) {
+			err = found(path)
 		}
+		return probableRomFiles, err
 	}
 
-	return probableRomFiles, nil
+	visited := make(map[string]bool)
+	err = opts.walk(path, "", 1, visited, found)
+
+	return probableRomFiles, err
 }
 
-func HasRomExtension(path string) bool {
-	ext := filepath.Ext(path)
-	for _, v := range Extensions {
-		if strings.EqualFold("."+v, ext) {
+func (opts FindOptions) walk(root string, rel string, depth int, visited map[string]bool, found func(string) error) error {
+	dir := filepath.Join(root, rel)
+
+	// Guard against symlink loops
+	if realpath, err := filepath.EvalSymlinks(dir); err == nil {
+		if visited[realpath] {
+			return nil
+		}
+		visited[realpath] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entryRel := filepath.Join(rel, entry.Name())
+		entryPath := filepath.Join(root, entryRel)
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			fi, err := os.Stat(entryPath)
+			if err != nil {
+				// Dangling symlink
+				continue
+			}
+			if fi.IsDir() && !opts.FollowSymlinks {
+				continue
+			}
+			isDir = fi.IsDir()
+		}
+
+		if isDir {
+			if !opts.Recursive || (opts.MaxDepth > 0 && depth >= opts.MaxDepth) {
+				continue
+			}
+			if matchesAny(opts.Exclude, entryRel) {
+				continue
+			}
+			if err := opts.walk(root, entryRel, depth+1, visited, found); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if opts.wantFile(entryPath, entryRel) {
+			if err := found(entryPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (opts FindOptions) wantFile(path string, rel string) bool {
+	if matchesAny(opts.Exclude, rel) {
+		return false
+	}
+	if len(opts.Include) > 0 && !matchesAny(opts.Include, rel) {
+		return false
+	}
+	if opts.Sniff {
+		content, err := SniffPath(path)
+		return err == nil && (content == ContentRom || content == ContentDisk || content == ContentArchive && IsArchive(path))
+	}
+	return len(opts.Include) > 0 || HasRomExtension(path) || IsArchive(path)
+}
+
+// Match glob patterns against the base name and the relative path
+func matchesAny(patterns []string, rel string) bool {
+	name := filepath.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
 			return true
 		}
 	}
 	return false
 }
+
+func HasRomExtension(path string) bool {
+	return hasExtension(path, Extensions)
+}
@@ -0,0 +1,35 @@
+package rom
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFindRomsSniffsDisks(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"game.bak": {0x80, 0x37, 0x12, 0x40},
+		"disk.bak": append([]byte{0xE8, 0x48, 0xD3, 0x16}, make([]byte, NDD_DISK_SIZE-4)...),
+		"save.bak": make([]byte, 512),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := FindRomsInPath(dir, FindOptions{Sniff: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, fpath := range found {
+		names = append(names, filepath.Base(fpath))
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "disk.bak" || names[1] != "game.bak" {
+		t.Errorf("found %v", names)
+	}
+}
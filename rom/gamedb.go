@@ -0,0 +1,170 @@
+package rom
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Save types and accessories of known games, one row per serial. A row with an
+// empty version applies to every version of the game. Edit it as a spreadsheet.
+//
+//go:embed gamedb.csv
+var embeddedGameDB []byte
+
+const (
+	SaveNone      = "none"
+	SaveEEPROM4K  = "eeprom4k"
+	SaveEEPROM16K = "eeprom16k"
+	SaveSRAM256K  = "sram256k"
+	SaveSRAM768K  = "sram768k"
+	SaveFlashRAM  = "flashram"
+	SaveSRAM1M    = "sram1m"
+)
+
+var SaveTypes = map[string]string{
+	SaveNone:      "None",
+	SaveEEPROM4K:  "EEPROM 4Kbit",
+	SaveEEPROM16K: "EEPROM 16Kbit",
+	SaveSRAM256K:  "SRAM 256Kbit",
+	SaveSRAM768K:  "SRAM 768Kbit",
+	SaveFlashRAM:  "FlashRAM 1Mbit",
+	SaveSRAM1M:    "SRAM 1Mbit",
+}
+
+// Accessories a game can use, in the order they're listed
+const (
+	AccessoryControllerPak = "controller_pak"
+	AccessoryRumblePak     = "rumble_pak"
+	AccessoryTransferPak   = "transfer_pak"
+	AccessoryExpansionPak  = "expansion_pak"
+	Accessory64DD          = "64dd"
+)
+
+var Accessories = []string{
+	AccessoryControllerPak,
+	AccessoryRumblePak,
+	AccessoryTransferPak,
+	AccessoryExpansionPak,
+	Accessory64DD,
+}
+
+// What the game database knows about a game
+type GameFeatures struct {
+	SaveType CodeDescription `json:"save_type" xml:"save_type"`
+	// Accessory codes from Accessories. Expansion Pak means supported or required.
+	Accessories []string `json:"accessories" xml:"accessories>accessory"`
+	Players     int      `json:"players" xml:"players"`
+}
+
+// Whether the game can use the accessory
+func (f *GameFeatures) Supports(accessory string) bool {
+	for _, a := range f.Accessories {
+		if a == accessory {
+			return true
+		}
+	}
+	return false
+}
+
+type gameDBKey struct {
+	serial  string
+	version string
+}
+
+var (
+	gameDB     map[gameDBKey]GameFeatures
+	gameDBErr  error
+	gameDBOnce sync.Once
+)
+
+// Look up a game by serial and version. Returns nil for games that aren't in the database.
+// Fails when the built-in database can't be read.
+func LookupFeatures(serial string, version uint8) (*GameFeatures, error) {
+	gameDBOnce.Do(func() {
+		gameDB, gameDBErr = readGameDB(bytes.NewReader(embeddedGameDB))
+	})
+	if gameDBErr != nil {
+		return nil, gameDBErr
+	}
+
+	for _, key := range []gameDBKey{{serial, fmt.Sprintf("1.%d", version)}, {serial, ""}} {
+		if features, ok := gameDB[key]; ok {
+			return &features, nil
+		}
+	}
+	return nil, nil
+}
+
+// Use the game database in a CSV file with the same columns as the built-in one,
+// instead of the built-in one. Must be called before any lookups.
+func LoadGameDB(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := readGameDB(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	gameDBOnce.Do(func() {})
+	gameDB, gameDBErr = db, nil
+	return nil
+}
+
+func readGameDB(r io.Reader) (map[gameDBKey]GameFeatures, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("Game database is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	for _, name := range append([]string{"serial", "version", "save_type", "players"}, Accessories...) {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("Game database is missing the %s column", name)
+		}
+	}
+
+	db := make(map[gameDBKey]GameFeatures, len(rows)-1)
+	for line, row := range rows[1:] {
+		saveType := row[columns["save_type"]]
+		description, ok := SaveTypes[saveType]
+		if !ok {
+			return nil, fmt.Errorf("Game database line %d: unknown save type '%s'", line+2, saveType)
+		}
+
+		players, err := strconv.Atoi(row[columns["players"]])
+		if err != nil {
+			return nil, fmt.Errorf("Game database line %d: invalid player count: %w", line+2, err)
+		}
+
+		features := GameFeatures{
+			SaveType:    CodeDescription{saveType, description},
+			Accessories: make([]string, 0),
+			Players:     players,
+		}
+		for _, accessory := range Accessories {
+			if strings.EqualFold(row[columns[accessory]], "yes") {
+				features.Accessories = append(features.Accessories, accessory)
+			}
+		}
+
+		db[gameDBKey{row[columns["serial"]], row[columns["version"]]}] = features
+	}
+	return db, nil
+}
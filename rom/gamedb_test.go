@@ -0,0 +1,79 @@
+package rom
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookupFeatures(t *testing.T) {
+	features, err := LookupFeatures("NSMJ", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if features == nil || !features.Supports(AccessoryRumblePak) {
+		t.Errorf("Shindou Edition: got %+v", features)
+	}
+
+	features, err = LookupFeatures("NSMJ", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if features == nil || features.Supports(AccessoryRumblePak) {
+		t.Errorf("the first Japanese version: got %+v", features)
+	}
+
+	if features, err := LookupFeatures("XXXX", 0); err != nil || features != nil {
+		t.Errorf("unknown game: got %+v, %v", features, err)
+	}
+}
+
+func TestReadGameDBErrors(t *testing.T) {
+	header := "serial,version,title,save_type,players,controller_pak,rumble_pak,transfer_pak,expansion_pak,64dd\n"
+	tests := map[string]string{
+		"empty":             "",
+		"missing column":    "serial,version,save_type\n",
+		"unknown save type": header + "NXXE,,Game,cassette,1,,,,,\n",
+		"invalid players":   header + "NXXE,,Game,none,many,,,,,\n",
+		"short row":         header + "NXXE,,Game,none\n",
+	}
+	for name, csv := range tests {
+		if _, err := readGameDB(strings.NewReader(csv)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestLoadGameDB(t *testing.T) {
+	if _, err := LookupFeatures("NSME", 0); err != nil {
+		t.Fatal(err)
+	}
+	builtIn := gameDB
+	defer func() { gameDB = builtIn }()
+
+	path := filepath.Join(t.TempDir(), "gamedb.csv")
+	csv := "serial,version,title,save_type,players,controller_pak,rumble_pak,transfer_pak,expansion_pak,64dd\n" +
+		"NXXE,,Game,flashram,2,,yes,,,\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadGameDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	features, err := LookupFeatures("NXXE", 0)
+	if err != nil || features == nil || features.SaveType.Code != SaveFlashRAM || features.Players != 2 {
+		t.Errorf("got %+v, %v", features, err)
+	}
+	if features, _ := LookupFeatures("NSME", 0); features != nil {
+		t.Error("the built-in database is still used")
+	}
+
+	if err := os.WriteFile(path, []byte("serial\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadGameDB(path); err == nil {
+		t.Error("no error for an invalid database")
+	}
+}
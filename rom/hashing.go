@@ -1,14 +1,39 @@
 package rom
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"hash"
+	"hash/crc32"
 	"io"
-	"os"
+	"strings"
 )
 
+// Hashes that can be calculated in a single pass over a ROM file
+const (
+	HashMD5    = "md5"
+	HashSHA1   = "sha1"
+	HashSHA256 = "sha256"
+	HashCRC32  = "crc32"
+	// The N64 CRC1 and CRC2. See CalcCRC.
+	HashN64CRC = "n64crc"
+
+	// Normalized hashes are calculated on the ROM data in Z64 byte order,
+	// no matter what order the file is in. These match the datfile.
+	HashRomMD5   = "rom_md5"
+	HashRomSHA1  = "rom_sha1"
+	HashRomCRC32 = "rom_crc32"
+)
+
+var Hashes = []string{
+	HashMD5, HashSHA1, HashSHA256, HashCRC32, HashN64CRC,
+	HashRomMD5, HashRomSHA1, HashRomCRC32,
+}
+
 func FileMD5(path string) (string, error) {
 	return hashToHex(path, md5.New())
 }
@@ -20,7 +45,7 @@ func FileSHA1(path string) (string, error) {
 func hashToHex(path string, hasher hash.Hash) (string, error) {
 	var hexHash string
 
-	file, err := os.Open(path)
+	file, err := openRom(path)
 	if err != nil {
 		return hexHash, err
 	}
@@ -36,40 +61,169 @@ func hashToHex(path string, hasher hash.Hash) (string, error) {
 }
 
 func (romfile *RomFile) AddMD5() error {
-	md5hex, err := FileMD5(romfile.File.Path)
-	if err != nil {
-		return err
-	}
-	romfile.File.MD5 = md5hex
-	return nil
+	return romfile.CalcHashes(HashMD5)
 }
 
 func (romfile *RomFile) AddSHA1() error {
-	sha1hex, err := FileSHA1(romfile.File.Path)
-	if err != nil {
-		return err
-	}
-	romfile.File.SHA1 = sha1hex
-	return nil
+	return romfile.CalcHashes(HashSHA1)
 }
 
 func (romfile *RomFile) AddHashes() error {
-	md5res := make(chan error)
-	sha1res := make(chan error)
+	return romfile.CalcHashes(HashMD5, HashSHA1)
+}
 
-	go func(errs chan error) {
-		errs <- romfile.AddMD5()
-	}(md5res)
-	go func(errs chan error) {
-		errs <- romfile.AddSHA1()
-	}(sha1res)
+// Calculate the requested hashes by reading the file once. The file is fed
+// through all the hashers at the same time with an io.MultiWriter.
+func (romfile *RomFile) CalcHashes(hashes ...string) error {
+	return romfile.CalcHashesContext(context.Background(), nil, hashes...)
+}
+
+// Same as CalcHashes, but stops reading when ctx is cancelled.
+// If progress is not nil, everything that's read is also written to it,
+// which makes it easy to count bytes as they're hashed.
+func (romfile *RomFile) CalcHashesContext(ctx context.Context, progress io.Writer, hashes ...string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	hashers := make(map[string]hash.Hash)
+	writers := make([]io.Writer, 0, len(hashes))
+	normalizedWriters := make([]io.Writer, 0)
+	var crcData *prefixWriter
+
+	// Z64 files and disks are already normalized, so they can go straight to the hashers
+	fileFormat := romfile.File.Format.Code
+	isNormalized := isNormalizedFormat(fileFormat)
+
+	for _, name := range hashes {
+		name = strings.ToLower(name)
+
+		var hasher hash.Hash
+		switch name {
+		case HashMD5:
+			hasher = md5.New()
+		case HashSHA1:
+			hasher = sha1.New()
+		case HashSHA256:
+			hasher = sha256.New()
+		case HashCRC32:
+			hasher = crc32.NewIEEE()
+		case HashRomMD5, HashRomSHA1, HashRomCRC32:
+			switch name {
+			case HashRomMD5:
+				hasher = md5.New()
+			case HashRomSHA1:
+				hasher = sha1.New()
+			case HashRomCRC32:
+				hasher = crc32.NewIEEE()
+			}
+			hashers[name] = hasher
+			if isNormalized {
+				writers = append(writers, hasher)
+			} else {
+				normalizedWriters = append(normalizedWriters, hasher)
+			}
+			continue
+		case HashN64CRC:
+			// Disks don't have a CRC
+			if crcData == nil && !romfile.IsDisk() {
+				crcData = &prefixWriter{buf: make([]byte, 0, CRC_CHECKSUM_END)}
+				writers = append(writers, crcData)
+			}
+			continue
+		default:
+			return fmt.Errorf("Unknown hash '%s'", name)
+		}
+		hashers[name] = hasher
+		writers = append(writers, hasher)
+	}
 
-	if err := <-md5res; err != nil {
+	file, err := openRom(romfile.File.Path)
+	if err != nil {
 		return err
 	}
-	if err := <-sha1res; err != nil {
+	defer file.Close()
+
+	var normalizer *ByteOrderWriter
+	if len(normalizedWriters) > 0 {
+		normalizer, err = NewByteOrderWriter(io.MultiWriter(normalizedWriters...), fileFormat, FormatZ64)
+		if err != nil {
+			return err
+		}
+		writers = append(writers, normalizer)
+	}
+
+	if progress != nil {
+		writers = append(writers, progress)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), &contextReader{ctx, file}); err != nil {
 		return err
 	}
+	if normalizer != nil {
+		if err := normalizer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	for name, hasher := range hashers {
+		sum := hex.EncodeToString(hasher.Sum(nil))
+		switch name {
+		case HashMD5:
+			romfile.File.MD5 = sum
+		case HashSHA1:
+			romfile.File.SHA1 = sum
+		case HashSHA256:
+			romfile.File.SHA256 = sum
+		case HashCRC32:
+			// No-Intro datfiles use upper-case CRC32s
+			romfile.File.CRC32 = strings.ToUpper(sum)
+		case HashRomMD5:
+			romfile.MD5 = sum
+		case HashRomSHA1:
+			romfile.SHA1 = sum
+		case HashRomCRC32:
+			romfile.CRC32 = strings.ToUpper(sum)
+		}
+	}
+
+	if crcData != nil {
+		if len(crcData.buf) < CRC_CHECKSUM_END {
+			return io.ErrUnexpectedEOF
+		}
+		if err := SwapByteOrder(crcData.buf, fileFormat, FormatZ64); err != nil {
+			return err
+		}
+		romfile.calcCRCFrom(crcData.buf, false)
+	}
 
 	return nil
 }
+
+// Keeps the first cap(buf) bytes written to it and discards the rest
+type prefixWriter struct {
+	buf []byte
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	if room := cap(pw.buf) - len(pw.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		pw.buf = append(pw.buf, p[:room]...)
+	}
+	return len(p), nil
+}
+
+// Stops reading once the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
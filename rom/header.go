@@ -0,0 +1,125 @@
+package rom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The header at the start of a cartridge ROM. Noted addresses are absolute to the file.
+// The first 4 bytes are the same in every ROM and only tell the byte order, kept in Format.
+// http://en64.shoutwiki.com/wiki/ROM#Cartridge_ROM_Header
+type Header struct {
+	// Byte order the header was read in and is written in: z64, v64, or n64.
+	// Empty is z64.
+	Format string
+
+	ClockRate      uint32   // 0x04
+	ProgramCounter uint32   // 0x08
+	ReleaseAddress uint32   // 0x0C
+	CRC1           uint32   // 0x10
+	CRC2           uint32   // 0x14
+	Unknown1       [8]byte  // 0x18
+	ImageName      [20]byte // 0x20
+	Unknown2       [4]byte  // 0x34
+	Unknown3       [3]byte  // 0x38
+	MediaFormat    byte     // 0x3B
+	CartridgeId    [2]byte  // 0x3C
+	RegionCode     byte     // 0x3E
+	Version        byte     // 0x3F
+}
+
+// Read the header from the start of a ROM. Nothing past the header is read.
+func ReadHeader(r io.Reader) (Header, error) {
+	var h Header
+	data := make([]byte, ROM_HEADER_SIZE)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return h, err
+	}
+	err := h.UnmarshalBinary(data)
+	return h, err
+}
+
+// Decode the header from the first 0x40 bytes of data, in any byte order.
+// Data can be longer, like a whole ROM.
+func (h *Header) UnmarshalBinary(data []byte) error {
+	if len(data) < ROM_HEADER_SIZE {
+		return fmt.Errorf("A ROM header is %d bytes, but only got %d", ROM_HEADER_SIZE, len(data))
+	}
+
+	format, err := detectRomFormat(data[0:4])
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, ROM_HEADER_SIZE)
+	copy(buf, data)
+	if err := SwapByteOrder(buf, format, FormatZ64); err != nil {
+		return err
+	}
+
+	h.Format = format
+	h.ClockRate = binary.BigEndian.Uint32(buf[0x04:])
+	h.ProgramCounter = binary.BigEndian.Uint32(buf[0x08:])
+	h.ReleaseAddress = binary.BigEndian.Uint32(buf[0x0C:])
+	h.CRC1 = binary.BigEndian.Uint32(buf[0x10:])
+	h.CRC2 = binary.BigEndian.Uint32(buf[0x14:])
+	copy(h.Unknown1[:], buf[0x18:0x20])
+	copy(h.ImageName[:], buf[0x20:0x34])
+	copy(h.Unknown2[:], buf[0x34:0x38])
+	copy(h.Unknown3[:], buf[0x38:0x3B])
+	h.MediaFormat = buf[0x3B]
+	copy(h.CartridgeId[:], buf[0x3C:0x3E])
+	h.RegionCode = buf[0x3E]
+	h.Version = buf[0x3F]
+	return nil
+}
+
+// Encode the header as 0x40 bytes in its Format byte order
+func (h Header) MarshalBinary() ([]byte, error) {
+	format := h.Format
+	if format == "" {
+		format = FormatZ64
+	}
+	if _, ok := FileFormats[format]; !ok {
+		return nil, fmt.Errorf("Can't write a ROM header in '%s' byte order", format)
+	}
+
+	buf := make([]byte, ROM_HEADER_SIZE)
+	copy(buf[0x00:], bomZ64)
+	binary.BigEndian.PutUint32(buf[0x04:], h.ClockRate)
+	binary.BigEndian.PutUint32(buf[0x08:], h.ProgramCounter)
+	binary.BigEndian.PutUint32(buf[0x0C:], h.ReleaseAddress)
+	binary.BigEndian.PutUint32(buf[0x10:], h.CRC1)
+	binary.BigEndian.PutUint32(buf[0x14:], h.CRC2)
+	copy(buf[0x18:0x20], h.Unknown1[:])
+	copy(buf[0x20:0x34], h.ImageName[:])
+	copy(buf[0x34:0x38], h.Unknown2[:])
+	copy(buf[0x38:0x3B], h.Unknown3[:])
+	buf[0x3B] = h.MediaFormat
+	copy(buf[0x3C:0x3E], h.CartridgeId[:])
+	buf[0x3E] = h.RegionCode
+	buf[0x3F] = h.Version
+
+	if err := SwapByteOrder(buf, FormatZ64, format); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// The image name without its padding
+func (h *Header) Name() string {
+	return strings.TrimSpace(bytesToString(h.ImageName[:]))
+}
+
+// Set the image name, padded with spaces. Longer names are cut off.
+func (h *Header) SetName(name string) {
+	for i := range h.ImageName {
+		if i < len(name) {
+			h.ImageName[i] = name[i]
+		} else {
+			h.ImageName[i] = ' '
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package rom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// A z64 header with every byte set, including the unknown fields
+func testHeaderBytes() []byte {
+	data := make([]byte, ROM_HEADER_SIZE)
+	copy(data, bomZ64)
+	for i := 4; i < len(data); i++ {
+		data[i] = byte(i*7 + 1)
+	}
+	copy(data[0x20:0x34], "HEADER TEST         ")
+	return data
+}
+
+func TestHeaderUnmarshalBinary(t *testing.T) {
+	var h Header
+	if err := h.UnmarshalBinary(testHeaderBytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Format != FormatZ64 {
+		t.Errorf("format: got %s", h.Format)
+	}
+	if h.ClockRate != 0x1D242B32 || h.CRC1 != 0x71787F86 || h.CRC2 != 0x8D949BA2 {
+		t.Errorf("got clock rate %08X, CRC1 %08X, CRC2 %08X", h.ClockRate, h.CRC1, h.CRC2)
+	}
+	if h.Unknown1 != [8]byte{0xA9, 0xB0, 0xB7, 0xBE, 0xC5, 0xCC, 0xD3, 0xDA} {
+		t.Errorf("unknown 0x18: got % X", h.Unknown1)
+	}
+	if h.Unknown2 != [4]byte{0x6D, 0x74, 0x7B, 0x82} || h.Unknown3 != [3]byte{0x89, 0x90, 0x97} {
+		t.Errorf("unknown 0x34 and 0x38: got % X and % X", h.Unknown2, h.Unknown3)
+	}
+	if h.Name() != "HEADER TEST" {
+		t.Errorf("name: got '%s'", h.Name())
+	}
+	if h.MediaFormat != 0x9E || h.CartridgeId != [2]byte{0xA5, 0xAC} || h.RegionCode != 0xB3 || h.Version != 0xBA {
+		t.Errorf("got media format %02X, cartridge ID % X, region %02X, version %02X",
+			h.MediaFormat, h.CartridgeId, h.RegionCode, h.Version)
+	}
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	z64 := testHeaderBytes()
+
+	for _, format := range []string{FormatZ64, FormatV64, FormatN64} {
+		data := append([]byte(nil), z64...)
+		if err := SwapByteOrder(data, FormatZ64, format); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := ReadHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if h.Format != format {
+			t.Errorf("%s: read as %s", format, h.Format)
+		}
+
+		out, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("%s: marshalled\n% X\nwant\n% X", format, out, data)
+		}
+
+		var z64Header Header
+		if err := z64Header.UnmarshalBinary(z64); err != nil {
+			t.Fatal(err)
+		}
+		z64Header.Format = format
+		if z64Header != h {
+			t.Errorf("%s: decoded differently from the z64 header", format)
+		}
+	}
+}
+
+func TestHeaderMarshalDefaultsToZ64(t *testing.T) {
+	h := Header{CRC1: 0x01020304}
+	out, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[0:4], bomZ64) || !bytes.Equal(out[0x10:0x14], []byte{1, 2, 3, 4}) {
+		t.Errorf("got % X", out[0:0x14])
+	}
+}
+
+func TestHeaderErrors(t *testing.T) {
+	var h Header
+	if err := h.UnmarshalBinary(testHeaderBytes()[:ROM_HEADER_SIZE-1]); err == nil {
+		t.Error("short data: no error")
+	}
+
+	unknown := testHeaderBytes()
+	copy(unknown, []byte{0x12, 0x34, 0x56, 0x78})
+	if err := h.UnmarshalBinary(unknown); err == nil {
+		t.Error("unknown byte order: no error")
+	}
+
+	if _, err := ReadHeader(bytes.NewReader(testHeaderBytes()[:0x20])); err != io.ErrUnexpectedEOF {
+		t.Errorf("short reader: got %v", err)
+	}
+
+	if _, err := (Header{Format: "x64"}).MarshalBinary(); err == nil {
+		t.Error("unknown format: no error")
+	}
+}
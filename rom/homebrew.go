@@ -0,0 +1,103 @@
+package rom
+
+import (
+	"fmt"
+)
+
+// The ED64 "Advanced Homebrew ROM Header" replaces the cartridge ID with "ED"
+// and uses the version byte to configure the save type, RTC, and region checks.
+// Flash-carts and emulators read it instead of looking the game up.
+const (
+	HOMEBREW_HEADER_OFFSET = 0x3C
+	HomebrewId             = "ED"
+)
+
+// Save types in the high nibble of the config byte
+var homebrewSaveTypes = []string{
+	SaveNone,
+	SaveEEPROM4K,
+	SaveEEPROM16K,
+	SaveSRAM256K,
+	SaveSRAM768K,
+	SaveFlashRAM,
+	SaveSRAM1M,
+}
+
+// Save type codes that can be set in the homebrew header
+func HomebrewSaveTypes() []string {
+	return append([]string(nil), homebrewSaveTypes...)
+}
+
+// Flags in the low nibble of the config byte
+const (
+	homebrewFlagRTC        = 0x01
+	homebrewFlagRegionFree = 0x02
+)
+
+type HomebrewHeader struct {
+	SaveType CodeDescription `json:"save_type" xml:"save_type"`
+	// The game uses the real-time clock
+	RTC bool `json:"rtc" xml:"rtc"`
+	// The game runs on consoles from any region
+	RegionFree bool `json:"region_free" xml:"region_free"`
+}
+
+// Decode the config byte. Save types this version doesn't know are kept as their number.
+func parseHomebrewConfig(config byte) *HomebrewHeader {
+	hb := HomebrewHeader{
+		RTC:        config&homebrewFlagRTC != 0,
+		RegionFree: config&homebrewFlagRegionFree != 0,
+	}
+
+	saveType := int(config >> 4)
+	if saveType < len(homebrewSaveTypes) {
+		code := homebrewSaveTypes[saveType]
+		hb.SaveType = CodeDescription{code, SaveTypes[code]}
+	} else {
+		hb.SaveType = CodeDescription{fmt.Sprintf("%d", saveType), "Unknown"}
+	}
+	return &hb
+}
+
+// Encode the header into the config byte
+func (hb *HomebrewHeader) config() (byte, error) {
+	for i, code := range homebrewSaveTypes {
+		if code != hb.SaveType.Code {
+			continue
+		}
+		config := byte(i << 4)
+		if hb.RTC {
+			config |= homebrewFlagRTC
+		}
+		if hb.RegionFree {
+			config |= homebrewFlagRegionFree
+		}
+		return config, nil
+	}
+	return 0, fmt.Errorf("Save type '%s' can't be set in a homebrew header", hb.SaveType.Code)
+}
+
+// Whether the ROM has the homebrew header
+func (r *RomFile) IsHomebrew() bool {
+	return r.Homebrew != nil
+}
+
+// Save type from the homebrew header, or else from the game database.
+// Empty when neither knows it.
+func (r *RomFile) SaveType() CodeDescription {
+	if r.Homebrew != nil {
+		return r.Homebrew.SaveType
+	}
+	if r.Features != nil {
+		return r.Features.SaveType
+	}
+	return CodeDescription{}
+}
+
+// Write the homebrew header to the ROM on disk, in the file's own byte order.
+// The cartridge ID becomes "ED" and the version byte holds the config;
+// the media format and region code are kept. Neither is covered by the CRCs.
+func (rf *RomFile) WriteHomebrewHeader(hb HomebrewHeader) error {
+	_, err := rf.EditHeader(HeaderChanges{Homebrew: &hb}, false)
+	return err
+}
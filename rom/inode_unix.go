@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package rom
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileInode(stat os.FileInfo) uint64 {
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+		return uint64(sys.Ino)
+	}
+	return 0
+}
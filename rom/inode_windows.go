@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package rom
+
+import "os"
+
+// Windows doesn't expose a file ID through os.Stat, so only size and modification time are compared
+func fileInode(stat os.FileInfo) uint64 {
+	return 0
+}
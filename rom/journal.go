@@ -0,0 +1,117 @@
+package rom
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A file operation done by organize, kept so it can be rolled back
+type JournalEntry struct {
+	Mode string `json:"mode"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// The destination directory. Empty directories up to here are removed on rollback.
+	Root string `json:"root"`
+	// The destination existed and was replaced. Rollback can't bring it back.
+	Replaced bool `json:"replaced,omitempty"`
+}
+
+// Records operations as they happen, one JSON object per line, so the journal
+// is usable even when organizing is interrupted.
+type Journal struct {
+	Path string
+	file *os.File
+}
+
+func CreateJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{Path: path, file: file}, nil
+}
+
+func (j *Journal) Record(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+func ReadJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]JournalEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Undo a journal entry. Moved files are moved back, and copies and links are removed.
+func (e JournalEntry) Rollback() error {
+	if e.Replaced {
+		return fmt.Errorf("'%s' replaced an existing file, which can't be restored", e.To)
+	}
+
+	switch e.Mode {
+	case OrganizeMove:
+		if _, err := os.Lstat(e.From); err == nil {
+			return fmt.Errorf("Can't move '%s' back, '%s' already exists", e.To, e.From)
+		}
+		if err := os.MkdirAll(filepath.Dir(e.From), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(e.To, e.From); err != nil {
+			return err
+		}
+	case OrganizeCopy, OrganizeLink:
+		if err := os.Remove(e.To); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown mode '%s'", e.Mode)
+	}
+
+	removeEmptyDirs(filepath.Dir(e.To), e.Root)
+	return nil
+}
+
+// Remove dir and its parents while they're empty, stopping at root
+func removeEmptyDirs(dir string, root string) {
+	prefix := filepath.Clean(root) + string(filepath.Separator)
+	for dir = filepath.Clean(dir); strings.HasPrefix(dir, prefix); dir = filepath.Dir(dir) {
+		// Fails when the directory isn't empty
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
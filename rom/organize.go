@@ -0,0 +1,280 @@
+package rom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/mroach/rom64/dat"
+)
+
+// How files are put in their new place
+const (
+	OrganizeCopy = "copy"
+	OrganizeMove = "move"
+	OrganizeLink = "link"
+)
+
+var OrganizeModes = []string{OrganizeCopy, OrganizeMove, OrganizeLink}
+
+// What to do when the destination already exists
+const (
+	// Leave both files alone
+	CollisionSkip = "skip"
+	// Add a number to the new name, e.g. "Game (2).z64"
+	CollisionRename = "rename"
+	// Replace the existing file
+	CollisionOverwrite = "overwrite"
+)
+
+var CollisionPolicies = []string{CollisionSkip, CollisionRename, CollisionOverwrite}
+
+// Values available to path templates
+type TemplateData struct {
+	*RomFile
+	// The matching datfile game. Nil when there's no match.
+	Game *dat.GameInfo
+}
+
+// Characters that aren't allowed in file names on at least one common platform
+var unsafePathChars = strings.NewReplacer(
+	"<", "_", ">", "_", ":", "_", `"`, "_", `\`, "_", "|", "_", "?", "_", "*", "_",
+)
+
+var templateFuncs = template.FuncMap{
+	// Make a value safe to use as a single path segment, e.g. {{.Region.Short | safe}} for JP/US
+	"safe":  func(s string) string { return strings.ReplaceAll(s, "/", "_") },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// Parse a template for organizing ROMs. The template is executed with TemplateData.
+func ParsePathTemplate(text string) (*template.Template, error) {
+	return template.New("path").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// Render the relative path for a ROM. Each path segment is cleaned up so it's
+// a valid file name, and the result can't point outside the destination.
+func (r *RomFile) RenderPath(tmpl *template.Template) (string, error) {
+	data := TemplateData{RomFile: r}
+	if r.Dat != nil {
+		data.Game = &r.Dat.Game
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		if data.Game == nil && TemplateUsesDat(tmpl) {
+			return "", fmt.Errorf("No datfile match for %s, which the template needs: %w", r.describeForDat(), err)
+		}
+		return "", err
+	}
+
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(out.String(), "/") {
+		segment = strings.TrimSpace(unsafePathChars.Replace(segment))
+		// Trailing dots aren't allowed on Windows
+		segment = strings.TrimRight(segment, ". ")
+		if segment == "" {
+			continue
+		}
+		if segment == ".." || segment == "." {
+			return "", fmt.Errorf("Template result '%s' can't contain '%s'", out.String(), segment)
+		}
+		segments = append(segments, segment)
+	}
+
+	if len(segments) == 0 {
+		return "", fmt.Errorf("Template result for '%s' is empty", r.File.Path)
+	}
+
+	return filepath.Join(segments...), nil
+}
+
+// Whether the template refers to the datfile game, as .Game or .Dat
+func TemplateUsesDat(tmpl *template.Template) bool {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && nodeUsesDat(t.Tree.Root) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeUsesDat(node parse.Node) bool {
+	var idents []string
+	var children []parse.Node
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			children = n.Nodes
+		}
+	case *parse.ActionNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.IfNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.RangeNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.WithNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.TemplateNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.PipeNode:
+		if n != nil {
+			for _, c := range n.Cmds {
+				children = append(children, c)
+			}
+		}
+	case *parse.CommandNode:
+		children = n.Args
+	case *parse.ChainNode:
+		children = []parse.Node{n.Node}
+		idents = n.Field
+	case *parse.FieldNode:
+		idents = n.Ident
+	case *parse.VariableNode:
+		idents = n.Ident
+	}
+
+	for _, ident := range idents {
+		if ident == "Game" || ident == "Dat" {
+			return true
+		}
+	}
+	for _, child := range children {
+		if child != nil && nodeUsesDat(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find where a file should go when dest may already be taken. The taken function
+// reports destinations already picked in this run, which may not exist on disk yet.
+// Those are never overwritten, since that would lose the file put there.
+// Returns an empty path when the file should be skipped.
+func ResolveCollision(dest string, policy string, taken func(string) bool) (string, error) {
+	planned := func(fpath string) bool {
+		return taken != nil && taken(fpath)
+	}
+	exists := func(fpath string) bool {
+		if planned(fpath) {
+			return true
+		}
+		_, err := os.Lstat(fpath)
+		return err == nil
+	}
+
+	if !exists(dest) {
+		return dest, nil
+	}
+
+	switch policy {
+	case CollisionSkip:
+		return "", nil
+	case CollisionOverwrite:
+		// Only files from before this run are replaced
+		if planned(dest) {
+			return "", nil
+		}
+		return dest, nil
+	case CollisionRename:
+		ext := filepath.Ext(dest)
+		base := strings.TrimSuffix(dest, ext)
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+			if !exists(candidate) {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Unknown collision policy '%s'. Valid policies: %s", policy, strings.Join(CollisionPolicies, ", "))
+}
+
+// Copy, move, or hard-link a ROM to dest, creating directories as needed.
+// ROMs inside archives can only be copied, which extracts them.
+func OrganizeFile(romfile *RomFile, dest string, mode string) error {
+	src := romfile.File.Path
+	inArchive := romfile.File.Archive != ""
+
+	if inArchive && mode != OrganizeCopy {
+		return fmt.Errorf("Can't %s ROMs inside archives. Use copy instead.", mode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	switch mode {
+	case OrganizeCopy:
+		return copyRom(src, dest)
+	case OrganizeMove:
+		err := os.Rename(src, dest)
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) && errors.Is(linkErr.Err, syscall.EXDEV) {
+			// Different filesystem. Copy and remove the original instead.
+			if err := copyRom(src, dest); err != nil {
+				return err
+			}
+			return os.Remove(src)
+		}
+		return err
+	case OrganizeLink:
+		// Hard links fail when the destination exists
+		if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return os.Link(src, dest)
+	}
+
+	return fmt.Errorf("Unknown mode '%s'. Valid modes: %s", mode, strings.Join(OrganizeModes, ", "))
+}
+
+// Copy through a temporary file so an interrupted copy doesn't leave a partial ROM behind
+func copyRom(src string, dest string) error {
+	in, err := openRom(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".rom64-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
+
+// Whether src and dest are already the same file, such as when a library is organized twice
+func SameFile(src string, dest string) bool {
+	a, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(dest)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(a, b)
+}
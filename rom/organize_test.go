@@ -0,0 +1,78 @@
+package rom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveCollisionPlannedDestination(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.z64")
+	if err := os.WriteFile(existing, []byte("rom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	planned := filepath.Join(dir, "planned.z64")
+	taken := func(fpath string) bool { return fpath == planned }
+
+	tests := []struct {
+		dest   string
+		policy string
+		want   string
+	}{
+		{existing, CollisionOverwrite, existing},
+		{planned, CollisionOverwrite, ""},
+		{existing, CollisionSkip, ""},
+		{planned, CollisionSkip, ""},
+		{planned, CollisionRename, filepath.Join(dir, "planned (2).z64")},
+		{filepath.Join(dir, "free.z64"), CollisionOverwrite, filepath.Join(dir, "free.z64")},
+	}
+	for _, tt := range tests {
+		got, err := ResolveCollision(tt.dest, tt.policy, taken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s with %s: got '%s', want '%s'", filepath.Base(tt.dest), tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateUsesDat(t *testing.T) {
+	tests := map[string]bool{
+		"{{.ImageName}}.z64":                                      false,
+		"{{.Region.Short | safe}}/{{.Serial}}":                    false,
+		"{{.Game.Name}}.z64":                                      true,
+		"{{if .Game}}{{.Game.Name}}{{else}}{{.ImageName}}{{end}}": true,
+		"{{with .Dat}}{{.Name}}{{end}}":                           true,
+		"{{range $i, $r := .File.Name}}{{$.Game}}{{end}}":         true,
+		"{{.RomFile.Dat.Name | lower}}":                           true,
+	}
+	for text, want := range tests {
+		tmpl, err := ParsePathTemplate(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := TemplateUsesDat(tmpl); got != want {
+			t.Errorf("%s: got %t, want %t", text, got, want)
+		}
+	}
+}
+
+func TestRenderPathWithoutDatMatch(t *testing.T) {
+	r := RomFile{ImageName: "GAME", File: FileInfo{Path: "game.z64"}}
+
+	tmpl, _ := ParsePathTemplate("{{.Game.Name}}.z64")
+	if _, err := r.RenderPath(tmpl); err == nil {
+		t.Error("expected an error for a template that needs the datfile game")
+	}
+
+	tmpl, _ = ParsePathTemplate("{{if .Game}}{{.Game.Name}}{{else}}{{.ImageName}}{{end}}.z64")
+	got, err := r.RenderPath(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "GAME.z64" {
+		t.Errorf("got '%s'", got)
+	}
+}
@@ -1,14 +1,14 @@
 package rom
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/binary"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"math"
 	"os"
-	"strings"
+
+	"github.com/mroach/rom64/dat"
 )
 
 const (
@@ -28,17 +28,6 @@ var bomZ64 = []byte{0x80, 0x37, 0x12, 0x40}
 var bomV64 = []byte{0x37, 0x80, 0x40, 0x12}
 var bomN64 = []byte{0x40, 0x12, 0x37, 0x80}
 
-var bootcodeChecksumToCIC = map[uint32]string{
-	0x587BD543: "5101",
-	0x6170A4A1: "6101",
-	0x90BB6CB5: "6102",
-	0x0B050EE0: "6103",
-	0x98BC2C86: "6105",
-	0xACC8580A: "6106",
-	0x009E9EA3: "7102",
-	0x0E018159: "8303",
-}
-
 var MediaFormats = map[string]string{
 	"N": "Cartridge",
 	"D": "64DD Disk",
@@ -83,24 +72,6 @@ var Regions = map[string]Region{
 	"Y": {"Y", "PAL/Y", "PAL Regions", PAL},
 }
 
-// The first 4 bytes of a ROM are used for endianness detection and will already
-// have been read by the time this struct is loaded. Noted address are absolute to the file.
-// http://en64.shoutwiki.com/wiki/ROM#Cartridge_ROM_Header
-type romFileHeader struct {
-	ClockRate      uint32   // 0x04
-	ProgramCounter uint32   // 0x08
-	ReleaseAddress uint32   // 0x0C
-	CRC1           uint32   // 0x10
-	CRC2           uint32   // 0x14
-	Unknown1       [8]byte  // 0x18
-	ImageName      [20]byte // 0x20
-	Unknown2       [4]byte  // 0x34
-	MediaFormat    [4]byte  // 0x38
-	CartridgeId    [2]byte  // 0x3C
-	RegionCode     [1]byte  // 0x3E
-	Version        byte     // 0x3F
-}
-
 type CodeDescription struct {
 	Code        string `json:"code" xml:"code"`
 	Description string `json:"description" xml:"description"`
@@ -113,8 +84,14 @@ type FileInfo struct {
 	Size   int             `json:"size" xml:"size"`
 	MD5    string          `json:"md5" xml:"md5"`
 	SHA1   string          `json:"sha1" xml:"sha1"`
+	SHA256 string          `json:"sha256,omitempty" xml:"sha256,omitempty"`
+	CRC32  string          `json:"crc32" xml:"crc32"`
 	CRC1   string          `json:"crc1" xml:"crc1"`
 	CRC2   string          `json:"crc2" xml:"crc2"`
+
+	// Set when the ROM is inside an archive. Path is then an archive entry path.
+	Archive      string `json:"archive,omitempty" xml:"archive,omitempty"`
+	ArchiveEntry string `json:"archive_entry,omitempty" xml:"archive_entry,omitempty"`
 }
 
 type RomFile struct {
@@ -126,7 +103,40 @@ type RomFile struct {
 	Region      Region          `json:"region" xml:"region"`
 	Version     uint8           `json:"version" xml:"version"`
 	CIC         string          `json:"cic" xml:"cic"`
-	File        FileInfo        `json:"file" xml:"file"`
+	// How sure the CIC detection is: high, medium, or low
+	CICConfidence string `json:"cic_confidence" xml:"cic_confidence"`
+	// CRC32 of the IPL3 bootcode, which identifies the CIC
+	IPL3CRC32 string   `json:"ipl3_crc32" xml:"ipl3_crc32"`
+	File      FileInfo `json:"file" xml:"file"`
+
+	// Hashes of the ROM data in Z64 byte order. Same as the File hashes for z64 files.
+	MD5   string `json:"md5" xml:"md5"`
+	SHA1  string `json:"sha1" xml:"sha1"`
+	CRC32 string `json:"crc32" xml:"crc32"`
+
+	// The matching datfile entry. Only set after MatchDat finds a match.
+	Dat *dat.Rom `json:"dat,omitempty" xml:"dat,omitempty"`
+
+	// Only set for 64DD disk images
+	Disk *DiskInfo `json:"disk,omitempty" xml:"disk,omitempty"`
+
+	// Save type and accessories from the game database. Nil for unknown games.
+	Features *GameFeatures `json:"features,omitempty" xml:"features,omitempty"`
+
+	// Only set for homebrew ROMs with the ED64 header
+	Homebrew *HomebrewHeader `json:"homebrew,omitempty" xml:"homebrew,omitempty"`
+
+	// The CIC found by trying every seed, when it isn't one of CICTypes
+	foundCIC *CICType
+}
+
+// SHA-1 of the ROM data in Z64 byte order. Uses the file's SHA-1 for z64 files
+// when the normalized hash wasn't calculated.
+func (r *RomFile) NormalizedSHA1() string {
+	if r.SHA1 == "" && isNormalizedFormat(r.File.Format.Code) {
+		return r.File.SHA1
+	}
+	return r.SHA1
 }
 
 // 4-char ROM identifier, e.g. NSME = Super Mario 64 (USA), NSMJ = Super Mario 64 (Japan)
@@ -134,21 +144,41 @@ func (r *RomFile) Serial() string {
 	return r.MediaFormat.Code + r.CartridgeId + r.Region.Id
 }
 
+// Read ROM info from a file on disk or from a ROM inside an archive.
+// See openRom for the paths that are accepted. 64DD disk images are read with FromDiskReader.
 func FromPath(path string) (RomFile, error) {
 	var info RomFile
 
-	f, err := os.Open(path)
+	src, err := openRom(path)
 	if err != nil {
 		return info, err
 	}
-	defer f.Close()
+	defer src.Close()
 
-	romfile, err := FromFile(f)
+	r := bufio.NewReader(src)
+	signature, err := r.Peek(4)
+	if err != nil {
+		return info, err
+	}
+
+	var romfile RomFile
+	if _, err := detectRomFormat(signature); err != nil && Sniff(signature, src.Size, src.Name) == ContentDisk {
+		romfile, err = FromDiskReader(r, src.Size)
+	} else {
+		romfile, err = FromIoReader(r)
+	}
 	if err != nil {
 		return romfile, err
 	}
 
 	romfile.File.Path = path
+	romfile.File.Size = romSize(src.Size)
+	romfile.File.Name = src.Name
+	if src.Archive != "" {
+		romfile.File.Path = ArchiveEntryPath(src.Archive, src.Entry)
+		romfile.File.Archive = src.Archive
+		romfile.File.ArchiveEntry = src.Entry
+	}
 	return romfile, nil
 }
 
@@ -170,65 +200,62 @@ func FromFile(fh *os.File) (RomFile, error) {
 }
 
 func FromIoReader(r io.Reader) (RomFile, error) {
-	var header romFileHeader
 	var info RomFile
 
-	// Read the first 4 bytes to detect the ROM file format
-
-	endiannessSignature := make([]byte, 4)
-	_, err := io.ReadFull(r, endiannessSignature)
-	if err != nil {
-		return info, err
-	}
-	romFormat, err := detectRomFormat(endiannessSignature[:])
+	header, err := ReadHeader(r)
 	if err != nil {
 		return info, err
 	}
 
-	headerBytes := make([]byte, ROM_HEADER_SIZE-len(endiannessSignature))
-	err = binary.Read(r, binary.BigEndian, headerBytes)
+	// Only read as far as the end of the bootcode
+	br, err := NewByteOrderReaderSize(r, header.Format, FormatZ64, CRC_CHECKSUM_START-ROM_HEADER_SIZE)
 	if err != nil {
 		return info, err
 	}
-	headerBytes = maybeReverseBytes(headerBytes, romFormat)
 
-	err = binary.Read(bytes.NewReader(headerBytes), binary.BigEndian, &header)
+	bootcode := make([]byte, CRC_CHECKSUM_START-ROM_HEADER_SIZE)
+	_, err = io.ReadFull(br, bootcode)
 	if err != nil {
 		return info, err
 	}
-
-	bootcode := make([]byte, 4032)
-	err = binary.Read(r, binary.BigEndian, &bootcode)
-	if err != nil {
-		return info, err
-	}
-	bootcode = maybeReverseBytes(bootcode, romFormat)
-	bootcodeHash := crc32.ChecksumIEEE(bootcode)
-	cic := bootcodeChecksumToCIC[bootcodeHash]
-
-	mediaFormatCode := bytesToString(header.MediaFormat[3:4])
-	regionCode := bytesToString(header.RegionCode[:])
+	mediaFormatCode := bytesToString([]byte{header.MediaFormat})
+	regionCode := bytesToString([]byte{header.RegionCode})
+	cic, cicConfidence, ipl3crc := detectCIC(bootcode, Regions[regionCode].VideoSystem)
 
 	info = RomFile{
-		ImageName:   strings.TrimSpace(bytesToString(header.ImageName[:])),
-		CartridgeId: bytesToString(header.CartridgeId[:]),
-		CIC:         cic,
-		CRC1:        fmt.Sprintf("%08X", header.CRC1),
-		CRC2:        fmt.Sprintf("%08X", header.CRC2),
-		Version:     header.Version,
-		Region:      Regions[regionCode],
+		ImageName:     header.Name(),
+		CartridgeId:   bytesToString(header.CartridgeId[:]),
+		CIC:           cic,
+		CICConfidence: cicConfidence,
+		IPL3CRC32:     fmt.Sprintf("%08X", ipl3crc),
+		CRC1:          fmt.Sprintf("%08X", header.CRC1),
+		CRC2:          fmt.Sprintf("%08X", header.CRC2),
+		Version:       header.Version,
+		Region:        Regions[regionCode],
 		MediaFormat: CodeDescription{
 			Code:        mediaFormatCode,
 			Description: MediaFormats[mediaFormatCode],
 		},
 		File: FileInfo{
 			Format: CodeDescription{
-				Code:        romFormat,
-				Description: FileFormats[romFormat],
+				Code:        header.Format,
+				Description: FileFormats[header.Format],
 			},
 		},
 	}
 
+	// The homebrew header reuses the version byte, so it isn't the version
+	if info.CartridgeId == HomebrewId {
+		info.Homebrew = parseHomebrewConfig(header.Version)
+		info.Version = 0
+	} else {
+		features, err := LookupFeatures(info.Serial(), info.Version)
+		if err != nil {
+			return info, err
+		}
+		info.Features = features
+	}
+
 	return info, nil
 }
 
@@ -241,6 +268,11 @@ func romSize(size int64) int {
 	return int(fsize / math.Pow(base, float64(exp)))
 }
 
+// Whether data in this format is already in Z64 byte order. 64DD disk images have no byte order.
+func isNormalizedFormat(format string) bool {
+	return format == FormatZ64 || isDiskFormat(format)
+}
+
 func detectRomFormat(signature []byte) (string, error) {
 	if bytes.Equal(signature, bomZ64) {
 		return FormatZ64, nil
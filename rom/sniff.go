@@ -0,0 +1,132 @@
+package rom
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// What kind of data a file holds, based on its contents
+const (
+	ContentRom     = "rom"
+	ContentDisk    = "disk"
+	ContentSave    = "save"
+	ContentArchive = "archive"
+	ContentUnknown = "unknown"
+)
+
+var ContentTypes = map[string]string{
+	ContentRom:     "Cartridge ROM",
+	ContentDisk:    "64DD disk image",
+	ContentSave:    "Save file",
+	ContentArchive: "Archive",
+	ContentUnknown: "Unknown",
+}
+
+// Size of a retail 64DD disk dump in the NDD layout
+const NDD_DISK_SIZE = 0x3DEC800
+
+// The first 4 bytes of the 64DD system area identify the disk region
+var (
+	diskIdJapan       = []byte{0xE8, 0x48, 0xD3, 0x16}
+	diskIdUSA         = []byte{0x22, 0x63, 0xEE, 0x56}
+	diskIdDevelopment = []byte{0x00, 0x00, 0x00, 0x00}
+)
+
+var archiveSignatures = [][]byte{
+	{'P', 'K', 0x03, 0x04},             // zip
+	{0x1F, 0x8B},                       // gzip
+	{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, // 7z
+	{'R', 'a', 'r', '!', 0x1A, 0x07},   // rar
+	{0x42, 0x5A, 0x68},                 // bzip2
+	{0xFD, '7', 'z', 'X', 'Z', 0x00},   // xz
+}
+
+var DiskExtensions = []string{"ndd", "d64"}
+
+// Save files have no signature, so rely on extensions and well-known sizes
+var SaveExtensions = []string{"eep", "sra", "fla", "mpk", "srm", "sav"}
+var saveSizes = []int64{
+	512,    // EEPROM 4K
+	2048,   // EEPROM 16K
+	32768,  // SRAM 256K, Controller Pak
+	131072, // FlashRAM 1M, SRAM 768K
+	296960, // Combined Mupen64 save
+}
+
+// Detect what kind of data a file holds by reading its first bytes
+func SniffPath(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ContentUnknown, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return ContentUnknown, err
+	}
+
+	signature := make([]byte, 8)
+	n, err := io.ReadFull(f, signature)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return ContentUnknown, err
+	}
+
+	return Sniff(signature[:n], stat.Size(), path), nil
+}
+
+// Detect what kind of data a file holds from its first bytes, its size, and its name.
+// The name is only used when the contents are ambiguous.
+func Sniff(signature []byte, size int64, name string) string {
+	if len(signature) >= 4 {
+		if _, err := detectRomFormat(signature[:4]); err == nil {
+			return ContentRom
+		}
+	}
+
+	for _, sig := range archiveSignatures {
+		if bytes.HasPrefix(signature, sig) {
+			return ContentArchive
+		}
+	}
+
+	if len(signature) >= 4 {
+		sig := signature[:4]
+		isDiskSize := size == NDD_DISK_SIZE
+		isDiskExt := hasExtension(name, DiskExtensions)
+
+		if bytes.Equal(sig, diskIdJapan) || bytes.Equal(sig, diskIdUSA) {
+			if isDiskSize || isDiskExt {
+				return ContentDisk
+			}
+		}
+		// Development disks have a blank region ID, so the size or name has to agree
+		if bytes.Equal(sig, diskIdDevelopment) && isDiskExt {
+			return ContentDisk
+		}
+	}
+
+	if hasExtension(name, SaveExtensions) {
+		return ContentSave
+	}
+	for _, saveSize := range saveSizes {
+		if size == saveSize {
+			return ContentSave
+		}
+	}
+
+	return ContentUnknown
+}
+
+func hasExtension(path string, extensions []string) bool {
+	ext := filepath.Ext(path)
+	for _, v := range extensions {
+		if strings.EqualFold("."+v, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,39 +1,197 @@
 package rom
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/mroach/rom64/dat"
 )
 
+// Find datfile entries matching the ROM's contents.
+//
+// The ROM is identified by its normalized hashes, strongest first: SHA-1, MD5, then CRC32
+// together with the size. The header serial isn't needed, so homebrew, hacks, and prototypes
+// with missing or shared serials can still be found. Entries with the same serial that
+// don't match the contents are returned as mismatches.
 func (r *RomFile) ValidateWithDat(df dat.DatFile) (matches, mismatches []dat.Rom, err error) {
-	if r.File.SHA1 == "" {
-		return matches, mismatches, fmt.Errorf("ROM file is missing a SHA-1 hash.")
+	matches, err = r.findDatMatches(df)
+	if err != nil {
+		return matches, mismatches, err
 	}
 
-	if r.File.Format.Code != FormatZ64 {
-		return matches, mismatches, fmt.Errorf(
-			"File must be in z64 (big-endian) format. This file is %s (%s). The `convert` command can help.",
-			r.File.Format.Code,
-			r.File.Format.Description)
+	serial := r.Serial()
+	if serial != "" {
+		for _, item := range df.FindBySerial(serial) {
+			if !containsRom(matches, item) {
+				mismatches = append(mismatches, item)
+			}
+		}
 	}
 
-	serial := r.Serial()
-	datroms := df.FindBySerial(serial)
-	if len(datroms) == 0 {
-		return matches, mismatches, fmt.Errorf("Datfile does not contain an entry for %s", serial)
+	if len(matches) == 0 && len(mismatches) == 0 {
+		return matches, mismatches, fmt.Errorf("Datfile does not contain an entry for %s", r.describeForDat())
+	}
+
+	return matches, mismatches, err
+}
+
+// Look up the ROM in the datfile and keep the first match in Dat.
+// Not finding a match is not an error; Dat is left nil.
+func (r *RomFile) MatchDat(df dat.DatFile) error {
+	matches, err := r.findDatMatches(df)
+	if err != nil {
+		return err
+	}
+
+	r.Dat = nil
+	if len(matches) > 0 {
+		r.Dat = &matches[0]
+	}
+	return nil
+}
+
+// Normalized hashes of the ROM. For z64 files and disks the file hashes are used when
+// the normalized ones weren't calculated.
+func (r *RomFile) normalizedHashes() (sha1, md5, crc32 string) {
+	sha1 = r.NormalizedSHA1()
+	md5 = r.MD5
+	crc32 = r.CRC32
+	if isNormalizedFormat(r.File.Format.Code) {
+		if md5 == "" {
+			md5 = r.File.MD5
+		}
+		if crc32 == "" {
+			crc32 = r.File.CRC32
+		}
 	}
+	return sha1, md5, crc32
+}
 
-	for _, item := range datroms {
-		sha1Match := strings.EqualFold(item.SHA1, r.File.SHA1)
+func (r *RomFile) findDatMatches(df dat.DatFile) (matches []dat.Rom, err error) {
+	sha1, md5, crc32 := r.normalizedHashes()
 
-		if sha1Match {
-			matches = append(matches, item)
-		} else {
-			mismatches = append(mismatches, item)
+	switch {
+	case sha1 != "":
+		matches = df.FindBySHA1(sha1)
+	case md5 != "":
+		matches = df.FindByMD5(md5)
+	case crc32 != "":
+		for _, item := range df.FindByCRC32(crc32) {
+			if romSize(int64(item.Size)) == r.File.Size {
+				matches = append(matches, item)
+			}
 		}
+	default:
+		return matches, fmt.Errorf("ROM file is missing a normalized (rom_sha1) SHA-1 hash.")
 	}
 
-	return matches, mismatches, err
+	return matches, nil
+}
+
+// Whether the ROM header serial agrees with the datfile entry.
+// Entries without a serial always agree.
+func (r *RomFile) SerialMatches(item dat.Rom) bool {
+	return item.Serial == "" || strings.EqualFold(item.Serial, r.Serial())
+}
+
+func (r *RomFile) describeForDat() string {
+	sha1, md5, crc32 := r.normalizedHashes()
+	hash := sha1
+	if hash == "" {
+		hash = md5
+	}
+	if hash == "" {
+		hash = crc32
+	}
+
+	if serial := r.Serial(); serial != "" {
+		return fmt.Sprintf("%s (%s)", serial, hash)
+	}
+	return hash
+}
+
+func containsRom(items []dat.Rom, item dat.Rom) bool {
+	for _, other := range items {
+		if other.Name == item.Name && strings.EqualFold(other.SHA1, item.SHA1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Outcome of validating a single file against the datfile
+const (
+	// The contents match a datfile entry
+	ValidationOK = "ok"
+	// The datfile has entries for the ROM's serial, but none match the contents
+	ValidationMismatch = "mismatch"
+	// Nothing in the datfile matches the contents or the serial
+	ValidationUnknown = "unknown"
+	// The file couldn't be read or hashed
+	ValidationError = "error"
+)
+
+type ValidationResult struct {
+	Path    string   `json:"path" xml:"path"`
+	Status  string   `json:"status" xml:"status"`
+	Serial  string   `json:"serial" xml:"serial"`
+	SHA1    string   `json:"sha1" xml:"sha1"`
+	Match   *dat.Rom `json:"match,omitempty" xml:"match,omitempty"`
+	Message string   `json:"message,omitempty" xml:"message,omitempty"`
+	// The file that was validated. Nil when it couldn't be read.
+	Rom *RomFile `json:"-" xml:"-"`
+}
+
+func (v *ValidationResult) OK() bool {
+	return v.Status == ValidationOK
+}
+
+// Read, hash, and validate the ROM at the given path. Problems are reported
+// in the result rather than returned as errors. The cache may be nil.
+func ValidatePath(path string, df dat.DatFile, cache *HashCache) (result ValidationResult) {
+	result.Path = path
+
+	romfile, err := FromPath(path)
+	if err != nil {
+		result.Status = ValidationError
+		result.Message = err.Error()
+		return result
+	}
+	result.Rom = &romfile
+	result.Serial = romfile.Serial()
+
+	if err := cache.CalcHashes(context.Background(), &romfile, nil, HashRomSHA1); err != nil {
+		result.Status = ValidationError
+		result.Message = err.Error()
+		return result
+	}
+	result.SHA1 = romfile.SHA1
+
+	matches, mismatches, err := romfile.ValidateWithDat(df)
+	switch {
+	case len(matches) > 1:
+		result.Status = ValidationError
+		result.Message = "Multiple datfile entries found with the same SHA-1 hash. This shouldn't happen."
+	case len(matches) == 1:
+		result.Status = ValidationOK
+		result.Match = &matches[0]
+		if !romfile.SerialMatches(matches[0]) {
+			result.Message = fmt.Sprintf("ROM header has serial '%s' but the datfile has '%s'", result.Serial, matches[0].Serial)
+		}
+	case len(mismatches) > 0:
+		names := make([]string, 0, len(mismatches))
+		for _, mismatch := range mismatches {
+			names = append(names, mismatch.Name)
+		}
+		result.Status = ValidationMismatch
+		result.Message = fmt.Sprintf("No checksum match. The datfile has: %s", strings.Join(names, ", "))
+	default:
+		result.Status = ValidationUnknown
+		if err != nil {
+			result.Message = err.Error()
+		}
+	}
+
+	return result
 }